@@ -17,15 +17,22 @@ limitations under the License.
 package app
 
 import (
-	"bytes"
+	"context"
+	"github.com/drew-viles/go-web-framework/binding"
+	"github.com/drew-viles/go-web-framework/client"
 	"github.com/drew-viles/go-web-framework/environment"
-	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/health"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/middleware"
 	"github.com/drew-viles/go-web-framework/routing"
+	"github.com/drew-viles/go-web-framework/tracing"
 	"github.com/drew-viles/go-web-framework/validation"
 	"github.com/gorilla/mux"
-	"io"
+	"net"
 	"net/http"
-	"path"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // Server is used to store router, config and validation info
@@ -33,42 +40,190 @@ type Server struct {
 	Router    *mux.Router
 	Validator *validation.Validator
 	Config    *environment.ConfigMap
+
+	// Middlewares run for every route, after the built-in authorisation checks and before any group or route middleware.
+	// See routing.SetupRoutesWithOptions for the full order.
+	Middlewares []middleware.Middleware
+
+	// DisableRequestID turns off the middleware that reads or generates an X-Request-ID for every request.
+	DisableRequestID bool
+
+	// DisableMetrics turns off the metrics middleware and the /metrics route.
+	DisableMetrics bool
+
+	// DisableTracing turns off the server span started for every request.
+	DisableTracing bool
+
+	// Tracer creates the server and client spans, tracing.Default is used when nil.
+	Tracer *tracing.Tracer
+
+	// DisableRecovery turns off the middleware that recovers panics in handlers and responds with a 500.
+	DisableRecovery bool
+
+	// PanicReporter, when set, is given every panic recovered from a handler.
+	PanicReporter middleware.PanicReporter
+
+	// AccessLog enables the access log middleware for every request when set.
+	AccessLog *middleware.AccessLogOptions
+
+	// DisableHealth turns off the /livez, /healthz and /readyz routes.
+	DisableHealth bool
+
+	// Health holds the checks run by /readyz. One is created by Initialise if not set, with checks for Config.DB
+	// and Config.Api.ApiEndpoint when they are configured. Further checks can be registered on it at any time.
+	// Check errors are logged rather than served by /readyz unless Health.SetVerbose is called.
+	Health *health.Checker
+
+	// PublishJWKS adds routing.JWKSRoute so other services can verify the tokens signed with auth.SetKeySet.
+	PublishJWKS bool
+
+	// Client calls Config.Api.ApiEndpoint, see InterfaceWithAPI and APIClient. One is created if not set.
+	Client     *client.Client
+	clientOnce sync.Once
+
+	// ShutdownTimeout is how long Run waits for in-flight requests to drain once shutdown starts.
+	// DefaultShutdownTimeout is used when it is zero.
+	ShutdownTimeout time.Duration
+
+	// ShutdownDelay is how long the server keeps accepting requests after readiness starts failing,
+	// giving load balancers time to stop routing traffic to it before the listener closes.
+	ShutdownDelay time.Duration
 }
 
 // Initialise create a new Gorilla mux router and initialises the []routing.Route passed into it
-func (s *Server) Initialise(routes *[]routing.Route) {
+// along with any route groups, each of which is mounted on a subrouter for its prefix.
+// The framework logger is configured from Config.Log when a level or format is set there, and the Validator, created if
+// not set, is used by binding.Bind.
+func (s *Server) Initialise(routes *[]routing.Route, groups ...routing.Group) {
+	if s.Config != nil && (s.Config.Log.Level != "" || s.Config.Log.Format != "") {
+		logging.SetDefault(logging.New(logging.Config{
+			Level:  s.Config.Log.Level,
+			Format: s.Config.Log.Format,
+		}))
+	}
+
+	if s.Validator == nil {
+		s.Validator = validation.New()
+	}
+	binding.SetValidator(s.Validator)
+
 	s.Router = mux.NewRouter()
-	routing.SetupRoutes(routes, s.Router)
+	s.APIClient()
+	routes = s.withBuiltinRoutes(routes)
+	options := s.routingOptions()
+	routing.SetupRoutesWithOptions(routes, s.Router, options)
+	routing.SetupGroups(groups, s.Router, options)
+	s.useRouterMiddlewares()
 }
 
-// InterfaceWithAPI creates a http client to send a request to another URL returning an array of bytes as the response.
-func (s *Server) InterfaceWithAPI(url string, method string, inputData []byte) (result []byte, err error) {
-	var req *http.Request
-	var res *http.Response
+// withBuiltinRoutes returns the routes along with those the framework provides itself.
+func (s *Server) withBuiltinRoutes(routes *[]routing.Route) *[]routing.Route {
+	var builtin []routing.Route
+	if !s.DisableMetrics {
+		builtin = append(builtin, routing.MetricsRoute())
+	}
+	if !s.DisableHealth {
+		s.setupHealth()
+		builtin = append(builtin, routing.LivenessRoutes(s.Health)...)
+		builtin = append(builtin, routing.ReadinessRoute(s.Health))
+	}
+	if s.PublishJWKS {
+		builtin = append(builtin, routing.JWKSRoute())
+	}
+	if len(builtin) == 0 {
+		return routes
+	}
+	// The built-in routes go last so a route the application already serves on the same path keeps taking precedence.
+	all := append(append([]routing.Route{}, *routes...), builtin...)
+	return &all
+}
 
-	apiURL := path.Join(s.Config.Api.ApiEndpoint, url)
-	client := &http.Client{}
+// setupHealth creates the health checker if needed and registers checks for the dependencies in the Config.
+// The checks replace those registered by an earlier Initialise as they are registered under the same names.
+func (s *Server) setupHealth() {
+	if s.Health == nil {
+		s.Health = health.NewChecker()
+	}
+	if s.Config == nil {
+		return
+	}
 
-	req, err = http.NewRequest(method, apiURL, bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
+	if s.Config.DB.Host != "" {
+		address := net.JoinHostPort(s.Config.DB.Host, strconv.Itoa(s.Config.DB.Port))
+		s.Health.Register(health.TCPCheck("db", address), health.CheckOptions{CacheFor: time.Second * 5})
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if s.Config.Api.ApiEndpoint != "" {
+		s.Health.Register(health.HTTPCheck("api", s.Config.Api.ApiEndpoint, nil), health.CheckOptions{CacheFor: time.Second * 5})
+		// An open circuit is a warning rather than a readiness failure, taking this instance out of rotation would not fix the upstream.
+		s.Health.Register(s.APIClient().HealthCheck(), health.CheckOptions{NonCritical: true})
+	}
+}
 
-	res, err = client.Do(req)
-	if err != nil {
-		return nil, err
+// routingOptions builds the settings applied to every route from the Config.
+func (s *Server) routingOptions() routing.Options {
+	options := routing.Options{
+		Middlewares: s.Middlewares,
+	}
+	if s.Config == nil {
+		return options
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+	cors := s.Config.App.CORS
+	if len(cors.AllowedOrigins) > 0 {
+		options.CORS = &middleware.CORSPolicy{
+			AllowedOrigins:   cors.AllowedOrigins,
+			AllowedMethods:   cors.AllowedMethods,
+			AllowedHeaders:   cors.AllowedHeaders,
+			ExposedHeaders:   cors.ExposedHeaders,
+			AllowCredentials: cors.AllowCredentials,
+			MaxAge:           cors.MaxAge,
+		}
 	}
+	return options
+}
 
-	if res.StatusCode != 200 {
-		return body, erroring.NotFoundError
+// InterfaceWithAPI sends a request to Config.Api.ApiEndpoint using the Client, returning an array of bytes as the response.
+// A response outside the 2xx range is returned along with a *client.StatusError, which unwraps to an erroring.Error
+// of the kind matching its status.
+//
+// Deprecated: InterfaceWithAPI is not bound to a request, so it is never cancelled and neither forwards the request ID
+// nor continues the trace. Use InterfaceWithAPIContext with the incoming request's context instead.
+func (s *Server) InterfaceWithAPI(url string, method string, inputData []byte) (result []byte, err error) {
+	return s.InterfaceWithAPIContext(context.Background(), url, method, inputData)
+}
+
+// InterfaceWithAPIContext is InterfaceWithAPI bound to a context. Passing the incoming request's context forwards its
+// request ID and continues its trace with a client span.
+func (s *Server) InterfaceWithAPIContext(ctx context.Context, url string, method string, inputData []byte) (result []byte, err error) {
+	res, err := s.APIClient().Do(ctx, client.Request{
+		Method: method,
+		Path:   url,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   inputData,
+	})
+	if res == nil {
+		return nil, err
 	}
+	return res.Body, err
+}
 
-	return body, nil
+// APIClient returns the Client, creating one for Config.Api.ApiEndpoint if none has been set.
+// The created client has a circuit breaker and bulkhead with their default options, waiting up to a second for a slot.
+func (s *Server) APIClient() *client.Client {
+	s.clientOnce.Do(func() {
+		if s.Client != nil {
+			return
+		}
+		options := client.Options{
+			Name:     "api",
+			Tracer:   s.Tracer,
+			Breaker:  &client.BreakerOptions{},
+			Bulkhead: &client.BulkheadOptions{MaxWait: time.Second},
+		}
+		if s.Config != nil {
+			options.BaseURL = s.Config.Api.ApiEndpoint
+		}
+		s.Client = client.New(options)
+	})
+	return s.Client
 }
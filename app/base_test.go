@@ -0,0 +1,49 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/drew-viles/go-web-framework/routing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuiltinRoutesDoNotReplaceApplicationRoutes(t *testing.T) {
+	routes := []routing.Route{{
+		Name:          "OwnReadiness",
+		Path:          "/readyz",
+		RequestMethod: http.MethodGet,
+		HandlerFunc: func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		},
+	}}
+	s := &Server{}
+	s.Initialise(&routes)
+
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("/readyz status = %d, want the application's handler", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/livez status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/drew-viles/go-web-framework/middleware"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// routerMiddlewares returns the middleware wrapping every request to the Router, outermost first.
+func (s *Server) routerMiddlewares() []mux.MiddlewareFunc {
+	var middlewares []mux.MiddlewareFunc
+	if !s.DisableRequestID {
+		middlewares = append(middlewares, middleware.RequestID)
+	}
+	if !s.DisableTracing {
+		middlewares = append(middlewares, middleware.Tracing(s.Tracer))
+	}
+	if s.AccessLog != nil {
+		middlewares = append(middlewares, middleware.AccessLog(*s.AccessLog))
+	}
+	if !s.DisableMetrics {
+		middlewares = append(middlewares, middleware.Metrics)
+	}
+	// Recovery is innermost so the middleware above record the 500 it responds with.
+	if !s.DisableRecovery {
+		middlewares = append(middlewares, middleware.Recovery(s.PanicReporter))
+	}
+	return middlewares
+}
+
+// useRouterMiddlewares adds the router wide middleware. mux only applies middleware to requests matching a route,
+// so the not found and method not allowed handlers are wrapped too.
+func (s *Server) useRouterMiddlewares() {
+	middlewares := s.routerMiddlewares()
+	if len(middlewares) == 0 {
+		return
+	}
+	s.Router.Use(middlewares...)
+
+	notFound := s.Router.NotFoundHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	methodNotAllowed := s.Router.MethodNotAllowedHandler
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		notFound = middlewares[i](notFound)
+		methodNotAllowed = middlewares[i](methodNotAllowed)
+	}
+	s.Router.NotFoundHandler = notFound
+	s.Router.MethodNotAllowedHandler = methodNotAllowed
+}
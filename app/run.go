@@ -0,0 +1,128 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/logging"
+	"net"
+	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is used when no ShutdownTimeout has been set on the Server.
+const DefaultShutdownTimeout = time.Second * 15
+
+// Address returns the host:port the server binds to, as defined by Config.App.IP and Config.App.Port.
+func (s *Server) Address() string {
+	return net.JoinHostPort(s.Config.App.IP, strconv.Itoa(s.Config.App.Port))
+}
+
+// Run binds to the configured address and serves the Router until ctx is cancelled or SIGINT/SIGTERM is received.
+// It returns nil when the server was shut down cleanly.
+func (s *Server) Run(ctx context.Context) error {
+	if s.Config == nil {
+		return errors.New("server has no config")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", s.Address())
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves the Router on the provided listener until ctx is cancelled.
+// HTTPS is served when Config.App.SSL holds a certificate pair, see tlsConfig.
+// Once cancelled, readiness starts failing and, after ShutdownDelay, new connections are refused and in-flight requests are given
+// ShutdownTimeout to complete.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if s.Router == nil {
+		return errors.New("server has not been initialised")
+	}
+
+	srv := &http.Server{
+		Handler:      s.Router,
+		WriteTimeout: time.Second * 15,
+		ReadTimeout:  time.Second * 15,
+		IdleTimeout:  time.Second * 60,
+	}
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+
+	if s.Config != nil {
+		tlsConfig, err := s.tlsConfig(watchCtx)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			logging.Default().Info("listening", "address", listener.Addr().String(), "tls", true)
+			serveErr <- srv.ServeTLS(listener, "", "")
+			return
+		}
+		logging.Default().Info("listening", "address", listener.Addr().String(), "tls", false)
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logging.Default().Info("shutting down", "timeout", s.shutdownTimeout())
+	if s.Health != nil {
+		s.Health.SetShuttingDown(true)
+	}
+	if s.ShutdownDelay > 0 {
+		time.Sleep(s.ShutdownDelay)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		_ = srv.Close()
+		return fmt.Errorf("graceful shutdown did not complete: %w", err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// shutdownTimeout returns the configured ShutdownTimeout or the default if none is set.
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.ShutdownTimeout > 0 {
+		return s.ShutdownTimeout
+	}
+	return DefaultShutdownTimeout
+}
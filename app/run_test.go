@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"github.com/drew-viles/go-web-framework/environment"
+	"github.com/drew-viles/go-web-framework/routing"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer initialises a Server whose /slow route blocks until release is closed, signalling started first.
+func newTestServer(t *testing.T) (s *Server, started chan struct{}, release chan struct{}) {
+	t.Helper()
+	started, release = make(chan struct{}), make(chan struct{})
+	s = &Server{
+		Config:         &environment.ConfigMap{},
+		DisableMetrics: true,
+	}
+	s.Config.App.IP = "127.0.0.1"
+	routes := []routing.Route{{
+		Name:          "Slow",
+		Path:          "/slow",
+		RequestMethod: http.MethodGet,
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusOK)
+		},
+	}}
+	s.Initialise(&routes)
+	return s, started, release
+}
+
+// serve runs Serve on a local listener, returning its address and a channel receiving its result.
+func serve(t *testing.T, ctx context.Context, s *Server) (string, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Serve(ctx, listener)
+	}()
+	return "http://" + listener.Addr().String(), result
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	s, started, release := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address, result := serve(t, ctx, s)
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get(address + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		_ = res.Body.Close()
+		status <- res.StatusCode
+	}()
+	<-started
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for !s.Health.ShuttingDown() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !s.Health.ShuttingDown() {
+		t.Error("readiness was not failed once shutdown started")
+	}
+	close(release)
+
+	if got := <-status; got != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", got, http.StatusOK)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Serve() error = %v, want nil", err)
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	s, started, release := newTestServer(t)
+	defer close(release)
+	s.ShutdownTimeout = time.Millisecond * 50
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address, result := serve(t, ctx, s)
+
+	go func() {
+		if res, err := http.Get(address + "/slow"); err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-result:
+		if err == nil || !strings.Contains(err.Error(), "graceful shutdown did not complete") {
+			t.Errorf("Serve() error = %v, want the shutdown to time out", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Serve did not return once the shutdown timeout passed")
+	}
+}
+
+func TestServeRequiresInitialise(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err = (&Server{}).Serve(context.Background(), listener); err == nil {
+		t.Error("Serve() error = nil for a server that has not been initialised")
+	}
+}
+
+func TestRunStopsWhenContextIsCancelled(t *testing.T) {
+	s, _, release := newTestServer(t)
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Run(ctx)
+	}()
+	time.Sleep(time.Millisecond * 50)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Run() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Run did not return once its context was cancelled")
+	}
+}
+
+func TestRunRequiresConfig(t *testing.T) {
+	if err := (&Server{}).Run(context.Background()); err == nil {
+		t.Error("Run() error = nil for a server without config")
+	}
+}
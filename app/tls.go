@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// certificateReloader holds the serving certificate in memory and reloads it from disk whenever the files change.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// newCertificateReloader loads the certificate pair and returns a reloader for it.
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	c := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads the certificate pair from disk, replacing the current one only if it is valid.
+func (c *certificateReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate pair: %w", err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate, always returning the most recently loaded certificate.
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// watch reloads the certificate pair whenever anything changes in the directories holding it, until ctx is cancelled.
+// Directories are watched rather than the files so that rotations done by renaming or swapping symlinks are caught.
+func (c *certificateReloader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]bool{
+		filepath.Dir(c.certFile): true,
+		filepath.Dir(c.keyFile):  true,
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if err := c.load(); err != nil {
+					logging.Default().Error("certificate change detected but could not be reloaded, keeping the current one", "error", err)
+					continue
+				}
+				logging.Default().Info("certificate reloaded", "file", event.Name)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.Default().Error("error watching certificates", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// tlsConfig builds the TLS config from Config.App.SSL. It returns nil if no certificate pair has been configured.
+// When CAKey is set, client certificates are verified against it and, if RequireClientCert is set, they are mandatory.
+// The certificate pair is reloaded from disk when it changes until ctx is cancelled.
+func (s *Server) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	ssl := s.Config.App.SSL
+	if ssl.PublicKey == "" && ssl.PrivateKey == "" {
+		if ssl.CAKey != "" || ssl.RequireClientCert {
+			return nil, errors.New("client certificate verification requires ssl.public_key and ssl.private_key to be set")
+		}
+		return nil, nil
+	}
+	if ssl.PublicKey == "" || ssl.PrivateKey == "" {
+		return nil, errors.New("both ssl.public_key and ssl.private_key must be set to serve TLS")
+	}
+
+	reloader, err := newCertificateReloader(ssl.PublicKey, ssl.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = reloader.watch(ctx); err != nil {
+		return nil, fmt.Errorf("watching certificates: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if ssl.CAKey != "" {
+		pem, err := os.ReadFile(ssl.CAKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", ssl.CAKey)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if ssl.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if ssl.RequireClientCert {
+		return nil, errors.New("ssl.require_client_cert is set but no ssl.ca_key has been provided to verify against")
+	}
+
+	return cfg, nil
+}
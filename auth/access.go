@@ -0,0 +1,102 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInsufficientAccess = errors.New("insufficient access")
+	ErrNoResolver         = errors.New("permissions are required but no PermissionResolver has been set")
+
+	permissionResolver PermissionResolver
+)
+
+// PermissionResolver looks up the permissions granted to a user, for example from a database.
+type PermissionResolver interface {
+	Permissions(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error)
+}
+
+// PermissionResolverFunc allows a plain function to be used as a PermissionResolver.
+type PermissionResolverFunc func(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error)
+
+// Permissions calls f.
+func (f PermissionResolverFunc) Permissions(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error) {
+	return f(ctx, userID, roles)
+}
+
+// SetPermissionResolver sets the resolver used to look up permissions when a Requirement lists any.
+func SetPermissionResolver(resolver PermissionResolver) {
+	permissionResolver = resolver
+}
+
+// Requirement describes what a caller must hold to access something.
+// The caller needs an access level of at least AccessLevel, any one of Roles and all of Permissions.
+type Requirement struct {
+	AccessLevel int
+	Roles       []string
+	Permissions []string
+}
+
+// IsZero reports whether the requirement has nothing to check.
+func (req Requirement) IsZero() bool {
+	return req.AccessLevel <= 0 && len(req.Roles) == 0 && len(req.Permissions) == 0
+}
+
+// CheckAccess returns ErrInsufficientAccess if the identity does not meet the requirement.
+// Any other error means the check itself could not be completed.
+func CheckAccess(ctx context.Context, identity Identity, req Requirement) error {
+	if identity.AccessLevel < req.AccessLevel {
+		return ErrInsufficientAccess
+	}
+
+	if len(req.Roles) > 0 && !containsAny(identity.Roles, req.Roles) {
+		return ErrInsufficientAccess
+	}
+
+	if len(req.Permissions) == 0 {
+		return nil
+	}
+	if permissionResolver == nil {
+		return ErrNoResolver
+	}
+	granted, err := permissionResolver.Permissions(ctx, identity.UserID, identity.Roles)
+	if err != nil {
+		return err
+	}
+	for _, permission := range req.Permissions {
+		if !containsAny(granted, []string{permission}) {
+			return ErrInsufficientAccess
+		}
+	}
+	return nil
+}
+
+// containsAny reports whether have holds at least one of want.
+func containsAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/responses"
+	"net/http"
+)
+
+// refreshRequest is the body expected by RefreshTokenHandler and, optionally, LogoutHandler.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssueTokenPairHandler returns a handler that issues a new token pair once authenticate has identified the user.
+// authenticate is responsible for checking the credentials on the request, any error it returns results in a 401.
+func IssueTokenPairHandler(authenticate func(r *http.Request) (Identity, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := authenticate(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+
+		pair, err := CreateTokenPairFor(identity)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("error issuing token pair", "error", err)
+			responses.ERROR(w, http.StatusInternalServerError, errors.New("unable to issue tokens"))
+			return
+		}
+		responses.JSON(w, http.StatusOK, pair)
+	}
+}
+
+// RefreshTokenHandler exchanges the refresh_token in a JSON body for a new token pair, rotating the refresh token.
+// The user is looked up again through the resolver set with SetIdentityResolver, if there is one.
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var body refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("a refresh_token is required"))
+		return
+	}
+
+	pair, err := RefreshTokenPair(r.Context(), body.RefreshToken)
+	if err != nil {
+		logging.FromContext(r.Context()).Info("error refreshing token", "error", err)
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	responses.JSON(w, http.StatusOK, pair)
+}
+
+// LogoutHandler revokes the access token used to make the request.
+// If the JSON body holds a refresh_token belonging to the same user it is revoked too.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	principal, err := Authenticate(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	if err = RevokeToken(principal.Claims); err != nil {
+		logging.FromContext(r.Context()).Error("error revoking token", "error", err)
+		responses.ERROR(w, http.StatusInternalServerError, errors.New("unable to log out"))
+		return
+	}
+
+	var body refreshRequest
+	if json.NewDecoder(r.Body).Decode(&body) == nil && body.RefreshToken != "" {
+		refreshClaims, err := parseToken(body.RefreshToken, RefreshTokenType)
+		if err == nil && refreshClaims.HashID == principal.Claims.HashID {
+			if _, err = refreshStore.Consume(refreshClaims.Id); err == nil {
+				err = RevokeToken(refreshClaims)
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error("error revoking refresh token", "error", err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
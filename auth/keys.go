@@ -0,0 +1,248 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey           = errors.New("token was signed with an unknown key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+	keySet *KeySet
+)
+
+// SigningKey is an asymmetric key identified by its ID, which is written to the kid header of the tokens it signs.
+// PrivateKey is nil for keys that are only used to verify tokens.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// GenerateSigningKey creates a new key for one of AlgorithmRS256, AlgorithmES256 or AlgorithmEdDSA with a random ID.
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(uuid.NewString(), signer)
+}
+
+// ParseSigningKeyPEM reads a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key, using id as its kid.
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, key)
+	}
+	return NewSigningKey(id, signer)
+}
+
+// NewSigningKey wraps a private key, choosing the signing method from its type.
+// RSA keys sign with RS256, P-256 keys with ES256 and Ed25519 keys with EdDSA.
+func NewSigningKey(id string, privateKey crypto.Signer) (*SigningKey, error) {
+	key := &SigningKey{
+		ID:         id,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: ECDSA keys must use P-256", ErrUnsupportedAlgorithm)
+		}
+		key.Method = jwt.SigningMethodES256
+	case ed25519.PrivateKey:
+		key.Method = SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, privateKey)
+	}
+	return key, nil
+}
+
+// KeySet holds the key currently used to sign tokens along with older keys that are still accepted when verifying.
+type KeySet struct {
+	mu      sync.RWMutex
+	active  *SigningKey
+	retired map[string]retiredKey
+}
+
+// retiredKey is a key that is still accepted for verification until the given time. A zero time never expires.
+type retiredKey struct {
+	key   *SigningKey
+	until time.Time
+}
+
+// NewKeySet creates a KeySet signing with active.
+func NewKeySet(active *SigningKey) *KeySet {
+	return &KeySet{
+		active:  active,
+		retired: map[string]retiredKey{},
+	}
+}
+
+// SetKeySet makes the package sign tokens with the KeySet's active key and verify them against all of its keys.
+// Without a KeySet tokens are signed with HS256 using environment.GetAPISecret. HS256 tokens without a kid are
+// still accepted once a KeySet is set, provided an API secret is configured, so that existing sessions survive the switch.
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// Active returns the key currently used for signing.
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// Add accepts an additional key for verification until the given time, zero meaning indefinitely.
+// This can be used to publish the next key ahead of a rotation or to trust keys held by other services.
+func (ks *KeySet) Add(key *SigningKey, until time.Time) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.retired[key.ID] = retiredKey{key: key, until: until}
+}
+
+// Rotate makes next the signing key. The previous key is still accepted for verification for the grace period,
+// which should be at least as long as the lifetime of any token it may have signed.
+func (ks *KeySet) Rotate(next *SigningKey, grace time.Duration) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.active != nil {
+		ks.retired[ks.active.ID] = retiredKey{key: ks.active, until: time.Now().Add(grace)}
+	}
+	delete(ks.retired, next.ID)
+	ks.active = next
+}
+
+// StartRotation replaces the signing key with one from generate every interval until ctx is cancelled.
+func (ks *KeySet) StartRotation(ctx context.Context, interval, grace time.Duration, generate func() (*SigningKey, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				next, err := generate()
+				if err != nil {
+					logging.Default().Error("error generating signing key, keeping the current one", "error", err)
+					continue
+				}
+				ks.Rotate(next, grace)
+				logging.Default().Info("signing key rotated", "kid", next.ID)
+			}
+		}
+	}()
+}
+
+// Lookup returns the key with the given ID if it is still valid for verification.
+func (ks *KeySet) Lookup(id string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.active != nil && ks.active.ID == id {
+		return ks.active, true
+	}
+	retired, ok := ks.retired[id]
+	if !ok || (!retired.until.IsZero() && time.Now().After(retired.until)) {
+		return nil, false
+	}
+	return retired.key, true
+}
+
+// Keys returns every key that is still valid for verification, the active key first.
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var keys []*SigningKey
+	if ks.active != nil {
+		keys = append(keys, ks.active)
+	}
+	var retiredKeys []*SigningKey
+	now := time.Now()
+	for id, retired := range ks.retired {
+		if !retired.until.IsZero() && now.After(retired.until) {
+			delete(ks.retired, id)
+			continue
+		}
+		retiredKeys = append(retiredKeys, retired.key)
+	}
+	sort.Slice(retiredKeys, func(i, j int) bool {
+		return retiredKeys[i].ID < retiredKeys[j].ID
+	})
+	return append(keys, retiredKeys...)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// principalKey is the context key the Principal is stored under.
+type principalKey struct{}
+
+// Principal is the verified identity behind a request, shared by handlers and middleware via the request context.
+type Principal struct {
+	UserID      uuid.UUID
+	Roles       []string
+	AccessLevel int
+	TokenID     string
+	Claims      *Claims
+}
+
+// Identity returns the identity the Principal's token was issued to.
+func (p *Principal) Identity() Identity {
+	return Identity{
+		UserID:      p.UserID,
+		Roles:       p.Roles,
+		AccessLevel: p.AccessLevel,
+	}
+}
+
+// NewContext returns a copy of ctx holding the Principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the Principal stored in ctx by the authorisation middleware, if there is one.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticate returns the Principal for the request.
+// If the request context already holds one it is returned as is, otherwise the access token is parsed and verified.
+func Authenticate(r *http.Request) (*Principal, error) {
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		return p, nil
+	}
+
+	claims, err := parseToken(extractToken(r), AccessTokenType)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := claims.Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		UserID:      identity.UserID,
+		Roles:       identity.Roles,
+		AccessLevel: identity.AccessLevel,
+		TokenID:     claims.Id,
+		Claims:      claims,
+	}, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRefreshTokenUsed = errors.New("refresh token has already been used or is unknown")
+	ErrUnknownUser      = errors.New("user no longer exists")
+
+	refreshStore     RefreshStore = NewMemoryRefreshStore()
+	identityResolver IdentityResolver
+)
+
+// IdentityResolver looks up a user's current roles and access level, for example from a database.
+// It should return ErrUnknownUser if the user no longer exists.
+type IdentityResolver interface {
+	Identity(ctx context.Context, userID uuid.UUID) (Identity, error)
+}
+
+// IdentityResolverFunc allows a plain function to be used as an IdentityResolver.
+type IdentityResolverFunc func(ctx context.Context, userID uuid.UUID) (Identity, error)
+
+// Identity calls f.
+func (f IdentityResolverFunc) Identity(ctx context.Context, userID uuid.UUID) (Identity, error) {
+	return f(ctx, userID)
+}
+
+// SetIdentityResolver sets the resolver used to look up the user's identity when a refresh token is exchanged.
+func SetIdentityResolver(resolver IdentityResolver) {
+	identityResolver = resolver
+}
+
+// TokenPair is an access token and the refresh token that can be exchanged for its replacement.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// RefreshStore records the refresh tokens that are still available for exchange.
+// Each refresh token can only be consumed once, which is what allows them to be rotated on use.
+type RefreshStore interface {
+	// Save records a newly issued refresh token.
+	Save(tokenID string, userID uuid.UUID, expiresAt time.Time) error
+	// Consume removes the refresh token, reporting whether it was available to be used.
+	Consume(tokenID string) (bool, error)
+}
+
+// SetRefreshStore replaces the store used to track refresh tokens. An in-memory store is used by default.
+func SetRefreshStore(store RefreshStore) {
+	refreshStore = store
+}
+
+// CreateTokenPair issues a new access token along with a refresh token for the user.
+func CreateTokenPair(hashID uuid.UUID) (*TokenPair, error) {
+	return CreateTokenPairFor(Identity{UserID: hashID})
+}
+
+// CreateTokenPairFor issues a new access token along with a refresh token, both carrying the identity's roles and access level.
+func CreateTokenPairFor(identity Identity) (*TokenPair, error) {
+	return createTokenPair(identity, refreshTokenLifetime())
+}
+
+// createTokenPair issues the token pair, with the refresh token lasting for refreshLifetime.
+func createTokenPair(identity Identity, refreshLifetime time.Duration) (*TokenPair, error) {
+	lifetime := accessTokenLifetime()
+	accessToken, _, err := signToken(identity, AccessTokenType, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshClaims, err := signToken(identity, RefreshTokenType, refreshLifetime)
+	if err != nil {
+		return nil, err
+	}
+	if err = refreshStore.Save(refreshClaims.Id, identity.UserID, time.Unix(refreshClaims.ExpiresAt, 0)); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(lifetime.Seconds()),
+	}, nil
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair.
+// The refresh token is consumed in the process so a second attempt to use it fails with ErrRefreshTokenUsed.
+// The user's roles and access level are looked up again through the IdentityResolver, or carried over from the refresh
+// token when none has been set with SetIdentityResolver. The new refresh token
+// expires when the one it replaces would have, so rotating tokens never extends the session.
+func RefreshTokenPair(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := parseToken(refreshToken, RefreshTokenType)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := claims.Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := refreshStore.Consume(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRefreshTokenUsed
+	}
+
+	identity := previous
+	if identityResolver != nil {
+		identity, err = identityResolver.Identity(ctx, previous.UserID)
+		if err != nil {
+			return nil, err
+		}
+		identity.UserID = previous.UserID
+	}
+
+	remaining := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if remaining <= 0 {
+		return nil, ErrRefreshTokenUsed
+	}
+	return createTokenPair(identity, remaining)
+}
+
+// MemoryRefreshStore is a RefreshStore held in memory. Tokens do not survive a restart.
+type MemoryRefreshStore struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewMemoryRefreshStore creates an empty MemoryRefreshStore.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		tokens: map[string]time.Time{},
+	}
+}
+
+// Save records the refresh token, pruning any that have since expired.
+func (m *MemoryRefreshStore) Save(tokenID string, _ uuid.UUID, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range m.tokens {
+		if now.After(expiry) {
+			delete(m.tokens, id)
+		}
+	}
+	m.tokens[tokenID] = expiresAt
+	return nil
+}
+
+// Consume removes the refresh token, reporting whether it was present and unexpired.
+func (m *MemoryRefreshStore) Consume(tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiry, ok := m.tokens[tokenID]
+	if !ok {
+		return false, nil
+	}
+	delete(m.tokens, tokenID)
+	return time.Now().Before(expiry), nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/drew-viles/go-web-framework/environment"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// useTestSecret signs tokens with a throwaway API secret and fresh stores for the duration of the test.
+func useTestSecret(t *testing.T) {
+	t.Helper()
+	configMap := &environment.ConfigMap{}
+	configMap.Api.ApiSecret = "test-secret"
+	environment.LoadSecurity(configMap)
+
+	previousRefresh, previousRevocation, previousResolver := refreshStore, revocationStore, identityResolver
+	refreshStore = NewMemoryRefreshStore()
+	revocationStore = NewMemoryRevocationStore()
+	t.Cleanup(func() {
+		environment.LoadSecurity(&environment.ConfigMap{})
+		refreshStore, revocationStore, identityResolver = previousRefresh, previousRevocation, previousResolver
+	})
+}
+
+func TestRefreshTokenPairRotates(t *testing.T) {
+	useTestSecret(t)
+	userID := uuid.New()
+	SetIdentityResolver(IdentityResolverFunc(func(_ context.Context, id uuid.UUID) (Identity, error) {
+		return Identity{UserID: id, Roles: []string{"viewer"}, AccessLevel: 1}, nil
+	}))
+
+	pair, err := CreateTokenPairFor(Identity{UserID: userID, Roles: []string{"admin"}, AccessLevel: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := parseToken(pair.RefreshToken, RefreshTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := RefreshTokenPair(context.Background(), pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := parseToken(refreshed.AccessToken, AccessTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.HashID != userID.String() || claims.AccessLevel != 1 || len(claims.Roles) != 1 || claims.Roles[0] != "viewer" {
+		t.Errorf("access token claims = %+v, want the identity from the resolver", claims)
+	}
+
+	rotated, err := parseToken(refreshed.RefreshToken, RefreshTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.ExpiresAt > original.ExpiresAt {
+		t.Errorf("rotated refresh token expires at %d, after the original %d", rotated.ExpiresAt, original.ExpiresAt)
+	}
+
+	if _, err = RefreshTokenPair(context.Background(), pair.RefreshToken); !errors.Is(err, ErrRefreshTokenUsed) {
+		t.Errorf("reusing the refresh token returned %v, want %v", err, ErrRefreshTokenUsed)
+	}
+}
+
+func TestRefreshTokenPairRejectsUnknownUser(t *testing.T) {
+	useTestSecret(t)
+	SetIdentityResolver(IdentityResolverFunc(func(context.Context, uuid.UUID) (Identity, error) {
+		return Identity{}, ErrUnknownUser
+	}))
+
+	pair, err := CreateTokenPair(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RefreshTokenPair(context.Background(), pair.RefreshToken); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("RefreshTokenPair() error = %v, want %v", err, ErrUnknownUser)
+	}
+}
+
+func TestRefreshTokenPairWithoutResolver(t *testing.T) {
+	useTestSecret(t)
+	SetIdentityResolver(nil)
+
+	pair, err := CreateTokenPairFor(Identity{UserID: uuid.New(), Roles: []string{"admin"}, AccessLevel: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := RefreshTokenPair(context.Background(), pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseToken(refreshed.AccessToken, AccessTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.AccessLevel != 3 || len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+		t.Errorf("access token claims = %+v, want the identity carried over from the refresh token", claims)
+	}
+}
+
+func TestMemoryRefreshStoreExpiry(t *testing.T) {
+	store := NewMemoryRefreshStore()
+	if err := store.Save("expired", uuid.New(), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Consume("expired"); err != nil || ok {
+		t.Errorf("Consume() = %v, %v, want false for an expired token", ok, err)
+	}
+}
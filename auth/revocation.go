@@ -0,0 +1,224 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	revocationStore RevocationStore = NewMemoryRevocationStore()
+)
+
+// RevocationStore records tokens that must no longer be accepted even though they have not yet expired.
+// Single tokens are keyed on their jti, whole users on a cut-off time before which their tokens were issued.
+type RevocationStore interface {
+	// Revoke marks the token as revoked. It only needs remembering until expiresAt.
+	Revoke(tokenID string, expiresAt time.Time) error
+	// IsRevoked reports whether the token has been revoked.
+	IsRevoked(tokenID string) (bool, error)
+	// RevokeUser revokes every token issued to the user before the given time.
+	RevokeUser(userID uuid.UUID, before time.Time) error
+	// RevokedBefore returns the time the user's tokens must have been issued at or after, zero if there is none.
+	RevokedBefore(userID uuid.UUID) (time.Time, error)
+}
+
+// SetRevocationStore replaces the store used to revoke tokens. An in-memory store is used by default.
+func SetRevocationStore(store RevocationStore) {
+	revocationStore = store
+}
+
+// RevokeToken revokes the token the claims belong to.
+func RevokeToken(claims *Claims) error {
+	return revocationStore.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+// RevokeAllForUser revokes every access and refresh token issued to the user so far, for example after a password reset.
+// Tokens issued after the call, such as those from logging in again straight away, are accepted.
+func RevokeAllForUser(userID uuid.UUID) error {
+	return revocationStore.RevokeUser(userID, time.Now())
+}
+
+// checkRevoked returns ErrTokenRevoked if the token, or every token of its user, has been revoked.
+func checkRevoked(claims *Claims) error {
+	revoked, err := revocationStore.IsRevoked(claims.Id)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	userID, err := uuid.Parse(claims.HashID)
+	if err != nil {
+		return err
+	}
+	before, err := revocationStore.RevokedBefore(userID)
+	if err != nil {
+		return err
+	}
+	if !before.IsZero() && claims.IssuedAtTime().Before(before) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// revocations is the state shared by the in-memory and file backed stores.
+type revocations struct {
+	Tokens map[string]time.Time    `json:"tokens"`
+	Users  map[uuid.UUID]time.Time `json:"users"`
+}
+
+// prune forgets revoked tokens that have since expired.
+func (rv *revocations) prune() {
+	now := time.Now()
+	for id, expiry := range rv.Tokens {
+		if now.After(expiry) {
+			delete(rv.Tokens, id)
+		}
+	}
+}
+
+// MemoryRevocationStore is a RevocationStore held in memory. Revocations do not survive a restart.
+type MemoryRevocationStore struct {
+	mu    sync.RWMutex
+	state revocations
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		state: revocations{
+			Tokens: map[string]time.Time{},
+			Users:  map[uuid.UUID]time.Time{},
+		},
+	}
+}
+
+// Revoke marks the token as revoked until it expires.
+func (m *MemoryRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.prune()
+	m.state.Tokens[tokenID] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether the token has been revoked.
+func (m *MemoryRevocationStore) IsRevoked(tokenID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.state.Tokens[tokenID]
+	return ok, nil
+}
+
+// RevokeUser revokes the user's tokens issued before the given time.
+func (m *MemoryRevocationStore) RevokeUser(userID uuid.UUID, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Users[userID] = before
+	return nil
+}
+
+// RevokedBefore returns the user's revocation cut-off.
+func (m *MemoryRevocationStore) RevokedBefore(userID uuid.UUID) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state.Users[userID], nil
+}
+
+// FileRevocationStore is a RevocationStore that keeps its state in memory and persists it to a JSON file on every change.
+// It suits single instance deployments, multiple instances should share a store backed by a database instead.
+type FileRevocationStore struct {
+	MemoryRevocationStore
+	path string
+}
+
+// NewFileRevocationStore creates a FileRevocationStore persisting to path, loading any revocations already saved there.
+func NewFileRevocationStore(path string) (*FileRevocationStore, error) {
+	f := &FileRevocationStore{
+		MemoryRevocationStore: *NewMemoryRevocationStore(),
+		path:                  path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &f.state); err != nil {
+		return nil, err
+	}
+	if f.state.Tokens == nil {
+		f.state.Tokens = map[string]time.Time{}
+	}
+	if f.state.Users == nil {
+		f.state.Users = map[uuid.UUID]time.Time{}
+	}
+	return f, nil
+}
+
+// Revoke marks the token as revoked until it expires and saves the change.
+func (f *FileRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.prune()
+	f.state.Tokens[tokenID] = expiresAt
+	return f.save()
+}
+
+// RevokeUser revokes the user's tokens issued before the given time and saves the change.
+func (f *FileRevocationStore) RevokeUser(userID uuid.UUID, before time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.Users[userID] = before
+	return f.save()
+}
+
+// save writes the state to a temporary file and renames it over the original so a crash never leaves it half written.
+// The caller must hold the lock.
+func (f *FileRevocationStore) save() error {
+	data, err := json.Marshal(f.state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevokeToken(t *testing.T) {
+	useTestSecret(t)
+	token, err := CreateToken(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseToken(token, AccessTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RevokeToken(claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = parseToken(token, AccessTokenType); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("parseToken() error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestRevokeAllForUserIncludesSameSecond(t *testing.T) {
+	useTestSecret(t)
+	userID, otherID := uuid.New(), uuid.New()
+	pair, err := CreateTokenPair(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := CreateToken(otherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RevokeAllForUser(userID); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{AccessTokenType: pair.AccessToken, RefreshTokenType: pair.RefreshToken} {
+		if _, err = parseToken(token, name); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("%s token error = %v, want %v", name, err, ErrTokenRevoked)
+		}
+	}
+	if _, err = parseToken(other, AccessTokenType); err != nil {
+		t.Errorf("another user's token error = %v, want nil", err)
+	}
+}
+
+func TestRevokeAllForUserAcceptsLaterTokens(t *testing.T) {
+	useTestSecret(t)
+	userID := uuid.New()
+	if err := RevokeAllForUser(userID); err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := CreateTokenPair(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = parseToken(pair.AccessToken, AccessTokenType); err != nil {
+		t.Errorf("token issued after revoking the user's tokens error = %v, want nil", err)
+	}
+	if _, err = RefreshTokenPair(context.Background(), pair.RefreshToken); err != nil {
+		t.Errorf("refreshing a pair issued after revoking the user's tokens error = %v, want nil", err)
+	}
+}
+
+func TestFileRevocationStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	store, err := NewFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Revoke("token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := reopened.IsRevoked("token"); err != nil || !revoked {
+		t.Errorf("IsRevoked() = %v, %v, want true after reopening", revoked, err)
+	}
+}
@@ -17,71 +17,255 @@ limitations under the License.
 package auth
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/drew-viles/go-web-framework/config"
 	"github.com/drew-viles/go-web-framework/environment"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/metrics"
 	"github.com/google/uuid"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 )
 
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+var (
+	ErrNoToken        = errors.New("no token provided")
+	ErrWrongTokenType = errors.New("token is not of the expected type")
+	ErrNoExpiry       = errors.New("token has no expiry")
+)
+
+// Claims are the claims carried by every token issued by this package.
+// The standard exp, iat, nbf and jti claims are always set and exp is required when validating.
+type Claims struct {
+	Authorized  bool     `json:"authorized"`
+	HashID      string   `json:"hash_id"`
+	TokenType   string   `json:"token_type"`
+	Roles       []string `json:"roles,omitempty"`
+	AccessLevel int      `json:"access_level,omitempty"`
+	// IssuedAtNano is iat in nanoseconds, so tokens can be told apart from a revocation made in the same second.
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
+	jwt.StandardClaims
+}
+
+// Identity is who a token is issued to and what they are allowed to do.
+type Identity struct {
+	UserID      uuid.UUID
+	Roles       []string
+	AccessLevel int
+}
+
+// Valid checks the standard time based claims, additionally refusing tokens that never expire.
+func (c Claims) Valid() error {
+	if c.ExpiresAt == 0 {
+		return ErrNoExpiry
+	}
+	return c.StandardClaims.Valid()
+}
+
 // CreateToken Creates a JSON Web Token.
+// The lifetime is taken from the configured token expiry, falling back to config.DefaultTokenExpiry.
 func CreateToken(hashID uuid.UUID) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["hash_id"] = hashID.String()
-	claims["expr"] = time.Now().Add(time.Hour * 1).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(environment.GetAPISecret())
+	return CreateTokenFor(Identity{UserID: hashID})
+}
+
+// CreateTokenFor creates a JSON Web Token carrying the roles and access level of the identity.
+func CreateTokenFor(identity Identity) (string, error) {
+	token, _, err := signToken(identity, AccessTokenType, accessTokenLifetime())
+	return token, err
 }
 
 // ExtractTokenUserID will extract the users id stored within the token
+// The Principal already in the request context is used when present, rather than parsing the token again.
 func ExtractTokenUserID(r *http.Request) (uuid.UUID, error) {
-	tokenString := extractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return environment.GetAPISecret(), nil
-	})
+	p, err := Authenticate(r)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return p.UserID, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		var uid uuid.UUID
-		uid, err = uuid.Parse(claims["hash_id"].(string))
-		if err != nil {
-			return uuid.Nil, err
-		}
-		return uid, nil
+// TokenValid takes a http request, checks for a token and validates it.
+func TokenValid(r *http.Request) error {
+	p, err := Authenticate(r)
+	if err != nil {
+		return err
 	}
+	logging.FromContext(r.Context()).Debug("user authenticated", "user_id", p.UserID, "token_id", p.TokenID)
+	return nil
+}
 
-	return uuid.Nil, nil
+// IssuedAtTime returns when the token was issued, to the nanosecond for tokens carrying iat_ns.
+func (c *Claims) IssuedAtTime() time.Time {
+	if c.IssuedAtNano != 0 {
+		return time.Unix(0, c.IssuedAtNano)
+	}
+	return time.Unix(c.IssuedAt, 0)
 }
 
-// TokenValid takes a http request, checks for a token and validates it.
-func TokenValid(r *http.Request) error {
-	tokenString := extractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// Identity returns the identity the token was issued to.
+func (c *Claims) Identity() (Identity, error) {
+	hashID, err := uuid.Parse(c.HashID)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{
+		UserID:      hashID,
+		Roles:       c.Roles,
+		AccessLevel: c.AccessLevel,
+	}, nil
+}
+
+// signToken creates and signs a token of the given type for the identity, returning it along with its claims.
+func signToken(identity Identity, tokenType string, lifetime time.Duration) (string, *Claims, error) {
+	now := time.Now()
+	claims := &Claims{
+		Authorized:   true,
+		HashID:       identity.UserID.String(),
+		TokenType:    tokenType,
+		Roles:        identity.Roles,
+		AccessLevel:  identity.AccessLevel,
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   identity.UserID.String(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+		},
+	}
+	token, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// sign signs the claims with the active key of the KeySet, or with the API secret if no KeySet has been set.
+func sign(claims *Claims) (string, error) {
+	if keySet == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(environment.GetAPISecret())
+	}
+
+	key := keySet.Active()
+	if key == nil || key.PrivateKey == nil {
+		return "", errors.New("the key set has no active signing key")
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// verificationKey picks the key to verify the token with from its kid header.
+// Tokens without a kid are verified with the API secret using HMAC.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, hasKid := token.Header["kid"].(string)
+	if !hasKid {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return environment.GetAPISecret(), nil
-	})
+		secret := environment.GetAPISecret()
+		if keySet != nil && len(secret) == 0 {
+			return nil, ErrUnknownKey
+		}
+		return secret, nil
+	}
+
+	if keySet == nil {
+		return nil, ErrUnknownKey
+	}
+	key, ok := keySet.Lookup(kid)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.PublicKey, nil
+}
 
+// parseToken verifies the token's signature and standard claims and ensures it is of the expected type and not revoked.
+// Failures are counted in metrics.TokenValidationFailures.
+func parseToken(tokenString string, tokenType string) (*Claims, error) {
+	claims, err := verifyToken(tokenString, tokenType)
 	if err != nil {
-		return err
+		metrics.TokenValidationFailures.WithLabelValues(tokenType, failureReason(err)).Inc()
+		return nil, err
 	}
+	return claims, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		pretty(claims)
+// verifyToken does the work of parseToken.
+func verifyToken(tokenString string, tokenType string) (*Claims, error) {
+	if tokenString == "" {
+		return nil, ErrNoToken
 	}
 
-	return nil
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
+	}
+	if err = checkRevoked(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// failureReason classifies why a token failed validation.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return "missing"
+	case errors.Is(err, ErrTokenRevoked):
+		return "revoked"
+	case errors.Is(err, ErrWrongTokenType):
+		return "wrong_type"
+	}
+
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) {
+		return "other"
+	}
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return "malformed"
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return "unverifiable"
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return "invalid_signature"
+	case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+		return "expired"
+	case validationErr.Errors&jwt.ValidationErrorNotValidYet != 0:
+		return "not_yet_valid"
+	default:
+		return "invalid_claims"
+	}
+}
+
+// accessTokenLifetime returns the configured access token lifetime or the default if none is set.
+func accessTokenLifetime() time.Duration {
+	if expiry := environment.GetTokenExpiry(); expiry > 0 {
+		return expiry
+	}
+	return config.DefaultTokenExpiry
+}
+
+// refreshTokenLifetime returns the configured refresh token lifetime or the default if none is set.
+func refreshTokenLifetime() time.Duration {
+	if interval := environment.GetRefreshInterval(); interval > 0 {
+		return interval
+	}
+	return config.DefaultRefreshTokenExpiry
 }
 
 // extractToken extracts a token from a http request
@@ -97,13 +281,3 @@ func extractToken(r *http.Request) string {
 	}
 	return ""
 }
-
-// pretty just prints things in a nice output
-func pretty(data interface{}) {
-	b, err := json.MarshalIndent(data, "", " ")
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Printf("user authenticated: \n%s\n", string(b))
-}
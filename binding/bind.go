@@ -0,0 +1,251 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package binding decodes requests into typed structs and validates them, see Bind and Handler.
+package binding
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/responses"
+	"github.com/drew-viles/go-web-framework/validation"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMaxBodyBytes is the largest request body Bind reads unless changed with SetMaxBodyBytes.
+const DefaultMaxBodyBytes = 1 << 20
+
+var (
+	defaultValidator atomic.Pointer[validation.Validator]
+	maxBodyBytes     atomic.Int64
+
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func init() {
+	maxBodyBytes.Store(DefaultMaxBodyBytes)
+}
+
+// SetValidator sets the validator Bind runs once the request is decoded. app.Server sets its Validator here.
+// Bind skips validation when none is set.
+func SetValidator(v *validation.Validator) {
+	defaultValidator.Store(v)
+}
+
+// SetMaxBodyBytes sets the largest request body Bind reads.
+func SetMaxBodyBytes(n int64) {
+	maxBodyBytes.Store(n)
+}
+
+// Bind decodes the request into a T, which must be a struct, then validates it.
+// The JSON body is decoded first, then fields tagged with path, query or header are set from mux.Vars, the query string
+// and the headers. Tagged fields are only ever set from those, anything the body held for them is cleared. For example:
+//
+//	type UpdateUser struct {
+//		ID     string `path:"id" validate:"uuid"`
+//		Notify bool   `query:"notify"`
+//		Tenant string `header:"X-Tenant" validate:"required"`
+//		Name   string `json:"name" validate:"required"`
+//	}
+//
+// A request that cannot be decoded returns a 400 erroring.Problem, or a 415 for a body that is not JSON,
+// and one that fails validation the 422 erroring.Problem from responses.ValidationProblem.
+func Bind[T any](r *http.Request) (T, error) {
+	var target T
+	value := reflect.ValueOf(&target).Elem()
+	if value.Kind() != reflect.Struct {
+		return target, fmt.Errorf("binding: %T is not a struct", target)
+	}
+
+	if err := decodeBody(r, &target); err != nil {
+		return target, err
+	}
+	if err := bindFields(r, value); err != nil {
+		return target, err
+	}
+	if err := validate(&target); err != nil {
+		return target, err
+	}
+	return target, nil
+}
+
+// decodeBody decodes a JSON body, if there is one, into target.
+func decodeBody(r *http.Request, target any) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return erroring.NewProblem(http.StatusUnsupportedMediaType, "request body must be JSON")
+		}
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes.Load()))
+	err := decoder.Decode(target)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return erroring.NewProblem(http.StatusRequestEntityTooLarge, "request body is too large")
+	case errors.As(err, &typeErr):
+		return erroring.NewProblem(http.StatusBadRequest, fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type)).
+			With("field", typeErr.Field)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return erroring.NewProblem(http.StatusBadRequest, "request body is not valid JSON")
+	default:
+		return erroring.NewProblem(http.StatusBadRequest, "request body could not be read")
+	}
+}
+
+// bindFields sets the fields tagged with path, query or header, including those of embedded structs.
+func bindFields(r *http.Request, value reflect.Value) error {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	fields := value.Type()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindFields(r, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var source, name string
+		var values []string
+		if name = field.Tag.Get("path"); name != "" {
+			source = "path parameter"
+			if v, ok := vars[name]; ok {
+				values = []string{v}
+			}
+		} else if name = field.Tag.Get("query"); name != "" {
+			source = "query parameter"
+			values = query[name]
+		} else if name = field.Tag.Get("header"); name != "" {
+			source = "header"
+			values = r.Header.Values(name)
+		}
+		if source == "" {
+			continue
+		}
+		value.Field(i).Set(reflect.Zero(field.Type))
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(value.Field(i), values); err != nil {
+			return erroring.NewProblem(http.StatusBadRequest, fmt.Sprintf("%s %s is invalid: %v", source, name, err)).
+				With("field", name)
+		}
+	}
+	return nil
+}
+
+// setField parses values into the field. Slices take every value, other kinds the first.
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !field.Addr().Type().Implements(textUnmarshaler) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setValue(slice.Index(i), v); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+// setValue parses a single value into v.
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Addr().Type().Implements(textUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive whole number")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// validate runs the validator set with SetValidator, if any.
+func validate(target any) error {
+	v := defaultValidator.Load()
+	if v == nil || v.Validate == nil {
+		return nil
+	}
+
+	err := v.Validate.Struct(target)
+	if fieldErrors := v.FieldErrors(err, target); fieldErrors != nil {
+		return responses.ValidationProblem(fieldErrors)
+	}
+	return err
+}
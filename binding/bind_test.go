@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binding
+
+import (
+	"errors"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/validation"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type updateUser struct {
+	ID     string   `path:"id"`
+	Notify bool     `query:"notify"`
+	Tags   []string `query:"tag"`
+	Tenant string   `header:"X-Tenant"`
+	Name   string   `json:"name" validate:"required"`
+}
+
+// newRequest creates a request with a JSON body and the given mux route variables.
+func newRequest(target, body string, vars map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(r, vars)
+}
+
+// useValidator sets a validator for the duration of the test.
+func useValidator(t *testing.T) {
+	t.Helper()
+	SetValidator(validation.New())
+	t.Cleanup(func() { SetValidator(nil) })
+}
+
+func TestBindPrecedence(t *testing.T) {
+	r := newRequest("/users/1?notify=true&tag=a&tag=b",
+		`{"name":"Ada","ID":"from-body","Notify":false,"Tenant":"from-body"}`, map[string]string{"id": "from-path"})
+	r.Header.Set("X-Tenant", "from-header")
+
+	got, err := Bind[updateUser](r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "from-path" || !got.Notify || got.Tenant != "from-header" || got.Name != "Ada" {
+		t.Errorf("Bind() = %+v, want path, query and header values over the body", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "a" || got.Tags[1] != "b" {
+		t.Errorf("Tags = %v, want every query value", got.Tags)
+	}
+}
+
+func TestBindIgnoresBodyForTaggedFields(t *testing.T) {
+	r := newRequest("/users/1", `{"name":"Ada","ID":"from-body","Tenant":"from-body"}`, nil)
+
+	got, err := Bind[updateUser](r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "" || got.Tenant != "" {
+		t.Errorf("Bind() = %+v, want tagged fields left empty when only the body sets them", got)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	useValidator(t)
+	tests := []struct {
+		name        string
+		target      string
+		body        string
+		contentType string
+		wantStatus  int
+	}{
+		{name: "invalid JSON", target: "/users/1", body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "wrong JSON type", target: "/users/1", body: `{"name":1}`, wantStatus: http.StatusBadRequest},
+		{name: "not JSON", target: "/users/1", body: `name=Ada`, contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "invalid query", target: "/users/1?notify=maybe", body: `{"name":"Ada"}`, wantStatus: http.StatusBadRequest},
+		{name: "failed validation", target: "/users/1", body: `{}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(tt.target, tt.body, nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			_, err := Bind[updateUser](r)
+			var problem *erroring.Problem
+			if !errors.As(err, &problem) {
+				t.Fatalf("Bind() error = %v, want a Problem", err)
+			}
+			if problem.Status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", problem.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBindValidationUsesJSONNames(t *testing.T) {
+	useValidator(t)
+	_, err := Bind[updateUser](newRequest("/users/1", `{}`, nil))
+
+	var problem *erroring.Problem
+	if !errors.As(err, &problem) {
+		t.Fatalf("Bind() error = %v, want a Problem", err)
+	}
+	fieldErrors, ok := problem.Extensions["errors"].([]validation.FieldError)
+	if !ok || len(fieldErrors) != 1 {
+		t.Fatalf("errors = %#v, want a single field error", problem.Extensions["errors"])
+	}
+	if fieldErrors[0].Field != "name" || fieldErrors[0].Rule != "required" {
+		t.Errorf("field error = %+v, want name failing required", fieldErrors[0])
+	}
+}
+
+func TestBindBodyTooLarge(t *testing.T) {
+	SetMaxBodyBytes(8)
+	t.Cleanup(func() { SetMaxBodyBytes(DefaultMaxBodyBytes) })
+
+	_, err := Bind[updateUser](newRequest("/users/1", `{"name":"a much longer name"}`, nil))
+	var problem *erroring.Problem
+	if !errors.As(err, &problem) || problem.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Bind() error = %v, want a 413 Problem", err)
+	}
+}
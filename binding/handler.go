@@ -0,0 +1,72 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binding
+
+import (
+	"context"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/responses"
+	"net/http"
+)
+
+// StatusCoder can be implemented by a Handler's response to choose the status it is sent with.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Handler wraps a typed handler func into a http.HandlerFunc. The request is bound into a Req with Bind and the Resp
+// is sent as JSON, with a 200 unless it implements StatusCoder.
+// Errors from binding or from fn are sent as problem details, with the status given by erroring.StatusOf.
+// Server errors, including errors with no known status, are logged and sent without their message.
+func Handler[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := Bind[Req](r)
+		if err != nil {
+			responses.PROBLEM(w, problemFor(r, err))
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			responses.PROBLEM(w, problemFor(r, err))
+			return
+		}
+
+		status := http.StatusOK
+		if coder, ok := any(resp).(StatusCoder); ok {
+			status = coder.StatusCode()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status == http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+		responses.JSON(w, status, resp)
+	}
+}
+
+// problemFor maps err to the Problem sent to the client. Server errors get a generic Problem so internal details,
+// such as the URL of a failing upstream, are only ever logged.
+func problemFor(r *http.Request, err error) *erroring.Problem {
+	problem := erroring.AsProblem(err, erroring.StatusOf(err))
+	if problem.Status != 0 && problem.Status < http.StatusInternalServerError {
+		return problem
+	}
+	logging.FromContext(r.Context()).Error("error handling request", "error", err)
+	return erroring.NewProblem(erroring.StatusOf(problem), "")
+}
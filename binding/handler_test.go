@@ -0,0 +1,84 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type createdUser struct {
+	Name string `json:"name"`
+}
+
+func (createdUser) StatusCode() int {
+	return http.StatusCreated
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(func(_ context.Context, req updateUser) (createdUser, error) {
+		return createdUser{Name: req.Name}, nil
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, newRequest("/users", `{"name":"Ada"}`, nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"name":"Ada"}` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestHandlerErrorDetail(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "client error", err: erroring.New(erroring.NotFoundError, "user not found"), wantStatus: http.StatusNotFound, wantDetail: "user not found"},
+		{name: "upstream error", err: erroring.New(erroring.UpstreamError, "GET http://internal:8080/users failed"), wantStatus: http.StatusBadGateway},
+		{name: "unknown error", err: errors.New("pq: connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Handler(func(context.Context, updateUser) (createdUser, error) {
+				return createdUser{}, tt.err
+			})
+			rec := httptest.NewRecorder()
+			handler(rec, newRequest("/users", `{"name":"Ada"}`, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var problem erroring.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", problem.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
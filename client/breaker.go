@@ -0,0 +1,197 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is how many consecutive failures open the circuit when FailureThreshold is zero.
+	DefaultFailureThreshold = 5
+	// DefaultOpenTimeout is how long the circuit stays open when OpenTimeout is zero.
+	DefaultOpenTimeout = time.Second * 30
+)
+
+// ErrCircuitOpen is returned without calling the upstream while its circuit is open. It is reported as a 503.
+var ErrCircuitOpen = erroring.New(erroring.UnavailableError, "circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen lets a few trial calls through to find out whether the upstream has recovered.
+	CircuitHalfOpen
+	// CircuitOpen fails every call fast.
+	CircuitOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// outcome is how a call counts towards the state of a circuit breaker.
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	// outcomeAbandoned is a call given up on by the caller, which says nothing about the upstream.
+	outcomeAbandoned
+)
+
+// BreakerOptions configure a circuit breaker. Network errors, timeouts and 5xx responses count as failures.
+type BreakerOptions struct {
+	// FailureThreshold is how many consecutive failures open the circuit, DefaultFailureThreshold is used when zero.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before trial calls are let through, DefaultOpenTimeout is used when zero.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many trial calls may run at once while half-open, and how many must succeed to close
+	// the circuit again. One is used when zero.
+	HalfOpenRequests int
+}
+
+// breaker is a circuit breaker for a single upstream.
+type breaker struct {
+	name    string
+	options BreakerOptions
+	now     func() time.Time
+
+	mu         sync.Mutex
+	state      CircuitState
+	generation int
+	failures   int
+	successes  int
+	trials     int
+	openedAt   time.Time
+}
+
+// newBreaker creates a closed breaker.
+func newBreaker(name string, options BreakerOptions) *breaker {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = DefaultFailureThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = DefaultOpenTimeout
+	}
+	if options.HalfOpenRequests <= 0 {
+		options.HalfOpenRequests = 1
+	}
+	b := &breaker{name: name, options: options, now: time.Now}
+	metrics.ClientCircuitState.WithLabelValues(name).Set(float64(CircuitClosed))
+	return b
+}
+
+// State returns the current state, moving from open to half-open once OpenTimeout has passed.
+func (b *breaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// allow reports whether a call may go ahead. If so, done must be called with its outcome.
+func (b *breaker) allow() (done func(outcome), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case CircuitOpen:
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.trials >= b.options.HalfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+		b.trials++
+	}
+
+	generation := b.generation
+	return func(result outcome) {
+		b.record(generation, result)
+	}, nil
+}
+
+// record counts the outcome of a call, ignoring calls that started before the last change of state.
+// An abandoned call only frees its trial slot while half-open, it counts as neither a success nor a failure.
+func (b *breaker) record(generation int, result outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	failed := result == outcomeFailure
+
+	switch b.state {
+	case CircuitClosed:
+		if result == outcomeAbandoned {
+			return
+		}
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.options.FailureThreshold {
+			b.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		b.trials--
+		if result == outcomeAbandoned {
+			return
+		}
+		if failed {
+			b.setState(CircuitOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.options.HalfOpenRequests {
+			b.setState(CircuitClosed)
+		}
+	}
+}
+
+// advance moves an open circuit to half-open once OpenTimeout has passed. b.mu must be held.
+func (b *breaker) advance() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.options.OpenTimeout {
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+// setState changes the state, resetting the counts. b.mu must be held.
+func (b *breaker) setState(state CircuitState) {
+	if state == CircuitOpen {
+		b.openedAt = b.now()
+	}
+	b.state = state
+	b.generation++
+	b.failures, b.successes, b.trials = 0, 0, 0
+	metrics.ClientCircuitState.WithLabelValues(b.name).Set(float64(state))
+}
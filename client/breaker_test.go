@@ -0,0 +1,129 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker creates a breaker whose clock only moves when advanced.
+func newTestBreaker(options BreakerOptions) (*breaker, func(time.Duration)) {
+	b := newBreaker("test", options)
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+// call makes a call through the breaker with the given outcome, failing the test if it is not allowed.
+func call(t *testing.T, b *breaker, result outcome) {
+	t.Helper()
+	done, err := b.allow()
+	if err != nil {
+		t.Fatalf("allow() error = %v in state %s", err, b.State())
+	}
+	done(result)
+}
+
+func TestBreakerStateTransitions(t *testing.T) {
+	b, advance := newTestBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	call(t, b, outcomeFailure)
+	call(t, b, outcomeSuccess)
+	call(t, b, outcomeFailure)
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("state = %s after non-consecutive failures, want closed", state)
+	}
+
+	call(t, b, outcomeFailure)
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("state = %s after consecutive failures, want open", state)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v while open, want %v", err, ErrCircuitOpen)
+	}
+
+	advance(time.Minute)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %s after the open timeout, want half-open", state)
+	}
+	call(t, b, outcomeFailure)
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("state = %s after a failed trial, want open", state)
+	}
+
+	advance(time.Minute)
+	call(t, b, outcomeSuccess)
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("state = %s after a successful trial, want closed", state)
+	}
+}
+
+func TestBreakerLimitsHalfOpenTrials(t *testing.T) {
+	b, advance := newTestBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute})
+	call(t, b, outcomeFailure)
+	advance(time.Minute)
+
+	done, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second trial allowed while half-open, error = %v", err)
+	}
+	done(outcomeSuccess)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("state = %s, want closed", state)
+	}
+}
+
+func TestBreakerIgnoresAbandonedCalls(t *testing.T) {
+	b, advance := newTestBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	call(t, b, outcomeFailure)
+	call(t, b, outcomeAbandoned)
+	call(t, b, outcomeFailure)
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("state = %s, want an abandoned call not to reset the failures", state)
+	}
+
+	advance(time.Minute)
+	call(t, b, outcomeAbandoned)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %s after an abandoned trial, want half-open", state)
+	}
+	call(t, b, outcomeSuccess)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("state = %s, want the freed trial slot to be usable", state)
+	}
+}
+
+func TestBreakerIgnoresCallsFromEarlierState(t *testing.T) {
+	b, advance := newTestBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute})
+	stale, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, b, outcomeFailure)
+	advance(time.Minute)
+
+	stale(outcomeFailure)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Errorf("state = %s after a call from before the circuit opened finished, want half-open", state)
+	}
+}
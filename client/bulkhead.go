@@ -0,0 +1,89 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/metrics"
+	"time"
+)
+
+// DefaultMaxConcurrent is how many calls may be made to an upstream at once when MaxConcurrent is zero.
+const DefaultMaxConcurrent = 64
+
+// ErrBulkheadFull is returned without calling the upstream when too many calls to it are already in flight.
+// It is reported as a 503.
+var ErrBulkheadFull = erroring.New(erroring.UnavailableError, "too many concurrent calls to upstream")
+
+// BulkheadOptions limit how many calls may be made to an upstream at once, so a slow upstream cannot tie up
+// every request being handled.
+type BulkheadOptions struct {
+	// MaxConcurrent is how many calls may be in flight at once, DefaultMaxConcurrent is used when zero.
+	MaxConcurrent int
+	// MaxWait is how long a call waits for a free slot before failing. Calls fail straight away when it is zero.
+	MaxWait time.Duration
+}
+
+// bulkhead limits the concurrent calls to a single upstream.
+type bulkhead struct {
+	name    string
+	maxWait time.Duration
+	slots   chan struct{}
+}
+
+// newBulkhead creates an empty bulkhead.
+func newBulkhead(name string, options BulkheadOptions) *bulkhead {
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = DefaultMaxConcurrent
+	}
+	return &bulkhead{
+		name:    name,
+		maxWait: options.MaxWait,
+		slots:   make(chan struct{}, options.MaxConcurrent),
+	}
+}
+
+// acquire takes a slot, waiting up to maxWait for one. release must be called once the call is over.
+func (b *bulkhead) acquire(ctx context.Context) (release func(), err error) {
+	release = func() {
+		<-b.slots
+		metrics.ClientRequestsInFlight.WithLabelValues(b.name).Dec()
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		metrics.ClientRequestsInFlight.WithLabelValues(b.name).Inc()
+		return release, nil
+	default:
+	}
+	if b.maxWait <= 0 {
+		return nil, ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		metrics.ClientRequestsInFlight.WithLabelValues(b.name).Inc()
+		return release, nil
+	case <-timer.C:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,449 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client makes outbound HTTP calls to upstream services, with pooled connections, timeouts, retries,
+// and request ID, trace and metrics propagation.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/metrics"
+	"github.com/drew-viles/go-web-framework/middleware"
+	"github.com/drew-viles/go-web-framework/tracing"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds each attempt when no Timeout is set.
+	DefaultTimeout = time.Second * 30
+	// DefaultMaxRetries is how many times an idempotent request is retried when MaxRetries is zero.
+	DefaultMaxRetries = 2
+	// DefaultRetryBackoff is the initial delay between retries when RetryBackoff is zero.
+	DefaultRetryBackoff = time.Millisecond * 100
+	// DefaultMaxBackoff caps the delay between retries when MaxBackoff is zero.
+	DefaultMaxBackoff = time.Second * 5
+)
+
+// Options configure a Client.
+type Options struct {
+	// Name labels the upstream in metrics and spans, "api" is used when it is empty.
+	Name string
+	// BaseURL is the URL request paths are resolved against.
+	BaseURL string
+	// Timeout bounds each attempt, including reading the response body, DefaultTimeout is used when it is zero.
+	Timeout time.Duration
+	// MaxRetries is how many times a failed idempotent request is retried, DefaultMaxRetries is used when it is zero.
+	// Set it below zero to disable retries.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries, doubled after each attempt and randomised.
+	RetryBackoff time.Duration
+	// MaxBackoff caps the delay between retries, including any asked for with Retry-After.
+	MaxBackoff time.Duration
+	// Header is sent with every request, unless the request sets the same header itself.
+	Header http.Header
+	// Authorize, when set, is called on every attempt to add credentials to the request, see BearerToken.
+	Authorize func(req *http.Request) error
+	// Transport sends the requests. A pooled transport is created when it is nil.
+	Transport http.RoundTripper
+	// Tracer creates the client spans, tracing.Default is used when nil.
+	Tracer *tracing.Tracer
+	// Breaker, when set, stops calls to the upstream for a while once it keeps failing.
+	Breaker *BreakerOptions
+	// Bulkhead, when set, limits how many calls may be made to the upstream at once.
+	Bulkhead *BulkheadOptions
+}
+
+// Request is a call to make to the upstream service.
+type Request struct {
+	Method string
+	// Path is resolved against the BaseURL, keeping the BaseURL's path. An absolute URL is used as it is.
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	// ContentType of Body, defaults to application/json when there is a body.
+	ContentType string
+	// Timeout overrides the client's Timeout for each attempt of this request, including reading the response body.
+	Timeout time.Duration
+}
+
+// Response is the response of a successful call.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Client calls an upstream service. It is safe for concurrent use.
+type Client struct {
+	options  Options
+	http     *http.Client
+	breaker  *breaker
+	bulkhead *bulkhead
+}
+
+// New creates a Client. Errors in the options, such as an invalid BaseURL, are returned by each call.
+func New(options Options) *Client {
+	if options.Name == "" {
+		options.Name = "api"
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultMaxBackoff
+	}
+	if options.Transport == nil {
+		options.Transport = newTransport()
+	}
+	c := &Client{
+		options: options,
+		// Redirects are followed by the http.Client, timeouts are applied per attempt through the context.
+		http: &http.Client{Transport: options.Transport},
+	}
+	if options.Breaker != nil {
+		c.breaker = newBreaker(options.Name, *options.Breaker)
+	}
+	if options.Bulkhead != nil {
+		c.bulkhead = newBulkhead(options.Name, *options.Bulkhead)
+	}
+	return c
+}
+
+// newTransport creates a transport keeping enough idle connections to each host for a busy service.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = time.Second * 90
+	return transport
+}
+
+// BearerToken authorizes requests with a static bearer token.
+func BearerToken(token string) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// Name returns the name the upstream is labelled with.
+func (c *Client) Name() string {
+	return c.options.Name
+}
+
+// Do sends the request, retrying idempotent requests that fail with a network error or a 429, 502, 503 or 504.
+// ErrCircuitOpen or ErrBulkheadFull are returned, wrapped, without calling the upstream when it is failing or busy.
+// A response outside the 2xx range is returned as a *StatusError, holding the status and body, along with the response.
+func (c *Client) Do(ctx context.Context, request Request) (*Response, error) {
+	res, err := c.Open(ctx, request)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			return &Response{Status: statusErr.Status, Header: statusErr.Header, Body: statusErr.Body}, err
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, erroring.Wrap(erroring.UpstreamError, err, "reading "+c.options.Name+" response")
+	}
+	return &Response{Status: res.StatusCode, Header: res.Header, Body: body}, nil
+}
+
+// Open is Do without reading the body of a successful response, for responses too large to hold in memory.
+// The caller must close the body. The attempt's timeout keeps applying until the body is closed, so reading the body
+// must finish within it too; set Request.Timeout for responses that take longer to read.
+func (c *Client) Open(ctx context.Context, request Request) (*http.Response, error) {
+	target, err := c.resolve(request)
+	if err != nil {
+		return nil, err
+	}
+	method := request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	tracer := c.options.Tracer
+	if tracer == nil {
+		tracer = tracing.Default()
+	}
+	ctx, span := tracer.Start(ctx, "HTTP "+method, tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("server.address", target.Host)
+	span.SetAttribute("url.full", redact(target))
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := c.send(ctx, span, method, target, request)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &closeBody{ReadCloser: res.Body, onClose: release}
+	return res, nil
+}
+
+// send makes the attempts at the request, retrying those that fail if the request can safely be sent again.
+func (c *Client) send(ctx context.Context, span *tracing.Span, method string, target *url.URL, request Request) (*http.Response, error) {
+	retries := 0
+	if retryable(method, request.Header) && c.options.MaxRetries > 0 {
+		retries = c.options.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		done, err := c.allow()
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		res, err := c.attempt(ctx, method, target, request)
+		done(outcomeOf(ctx, res, err))
+
+		if attempt < retries && shouldRetry(ctx, res, err) {
+			delay := c.backoff(attempt, res)
+			logging.FromContext(ctx).Debug("retrying upstream request", "upstream", c.options.Name, "method", method,
+				"attempt", attempt+1, "delay", delay, "error", err)
+			if res != nil {
+				discard(res)
+			}
+			if err := sleep(ctx, delay); err != nil {
+				span.RecordError(err)
+				return nil, erroring.Wrap(erroring.TimeoutError, err, "calling "+c.options.Name)
+			}
+			continue
+		}
+		span.SetAttribute("http.resend_count", attempt)
+
+		if err != nil {
+			span.RecordError(err)
+			return nil, c.transportError(err)
+		}
+		span.SetAttribute("http.response.status_code", res.StatusCode)
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			statusErr := newStatusError(method, target, res)
+			if res.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(tracing.StatusError, res.Status)
+			}
+			return nil, statusErr
+		}
+		return res, nil
+	}
+}
+
+// acquire takes a slot in the bulkhead, if there is one. release must be called once the call is over.
+func (c *Client) acquire(ctx context.Context) (release func(), err error) {
+	if c.bulkhead == nil {
+		return func() {}, nil
+	}
+	release, err = c.bulkhead.acquire(ctx)
+	if errors.Is(err, ErrBulkheadFull) {
+		metrics.ClientRejections.WithLabelValues(c.options.Name, "bulkhead_full").Inc()
+		return nil, fmt.Errorf("calling %s: %w", c.options.Name, err)
+	}
+	return release, err
+}
+
+// allow asks the circuit breaker, if there is one, whether an attempt may be made.
+func (c *Client) allow() (done func(outcome), err error) {
+	if c.breaker == nil {
+		return func(outcome) {}, nil
+	}
+	done, err = c.breaker.allow()
+	if err != nil {
+		metrics.ClientRejections.WithLabelValues(c.options.Name, "circuit_open").Inc()
+		return nil, fmt.Errorf("calling %s: %w", c.options.Name, err)
+	}
+	return done, nil
+}
+
+// outcomeOf reports how an attempt counts towards the circuit breaker. A network error, a timeout or a 5xx is a
+// failure of the upstream, while calls abandoned by the caller count as neither a success nor a failure.
+func outcomeOf(ctx context.Context, res *http.Response, err error) outcome {
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeAbandoned
+		}
+		return outcomeFailure
+	}
+	if res.StatusCode >= http.StatusInternalServerError {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// CircuitState returns the state of the circuit breaker, CircuitClosed if the client has none.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.State()
+}
+
+// attempt sends the request once, recording metrics for it.
+func (c *Client) attempt(ctx context.Context, method string, target *url.URL, request Request) (*http.Response, error) {
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = c.options.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(request.Body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for key, values := range c.options.Header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	for key, values := range request.Header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	if len(request.Body) > 0 {
+		contentType := request.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	if requestID := middleware.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+	tracing.Inject(ctx, req.Header)
+	if c.options.Authorize != nil {
+		if err := c.options.Authorize(req); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	res, err := c.http.Do(req)
+	metrics.ClientRequestDuration.WithLabelValues(c.options.Name, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		cancel()
+		metrics.ClientRequests.WithLabelValues(c.options.Name, method, "error").Inc()
+		return nil, err
+	}
+	metrics.ClientRequests.WithLabelValues(c.options.Name, method, metrics.StatusClass(res.StatusCode)).Inc()
+
+	// The attempt's context must stay alive until the body has been read.
+	res.Body = &closeBody{ReadCloser: res.Body, onClose: cancel}
+	return res, nil
+}
+
+// resolve returns the URL of the request. Paths are appended to the BaseURL rather than replacing its last segment.
+func (c *Client) resolve(request Request) (*url.URL, error) {
+	ref, err := url.Parse(request.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !ref.IsAbs() {
+		if c.options.BaseURL == "" {
+			return nil, errors.New("client has no base URL to resolve " + request.Path + " against")
+		}
+		base, err := url.Parse(c.options.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		if !base.IsAbs() {
+			return nil, errors.New("base URL " + c.options.BaseURL + " is not absolute")
+		}
+		if !strings.HasSuffix(base.Path, "/") {
+			base.Path += "/"
+			if base.RawPath != "" {
+				base.RawPath += "/"
+			}
+		}
+		ref.Path = strings.TrimPrefix(ref.Path, "/")
+		ref.RawPath = strings.TrimPrefix(ref.RawPath, "/")
+		ref = base.ResolveReference(ref)
+	}
+
+	if len(request.Query) > 0 {
+		query := ref.Query()
+		for key, values := range request.Query {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		ref.RawQuery = query.Encode()
+	}
+	return ref, nil
+}
+
+// transportError classifies an error sending the request as a timeout or an upstream failure.
+func (c *Client) transportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return erroring.Wrap(erroring.TimeoutError, err, "calling "+c.options.Name)
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return erroring.Wrap(erroring.UpstreamError, err, "calling "+c.options.Name)
+}
+
+// closeBody calls onClose once the body is closed, to release the attempt's context or bulkhead slot.
+type closeBody struct {
+	io.ReadCloser
+	onClose func()
+	once    sync.Once
+}
+
+// Close closes the body and calls onClose, only the first time it is called.
+func (b *closeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
+
+// discard drains and closes the body so the connection can be reused.
+func discard(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	_ = res.Body.Close()
+}
+
+// redact removes credentials and the query from a URL before it is recorded.
+func redact(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	return redacted.String()
+}
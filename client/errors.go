@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody caps how much of an error response's body is kept.
+const maxErrorBody = 1 << 20
+
+// StatusError is returned when the upstream responds outside the 2xx range.
+// It unwraps to an erroring.Error of the kind matching the status, so errors.Is(err, erroring.NotFoundError)
+// and erroring.StatusOf work as they would for a local error.
+type StatusError struct {
+	Method string
+	URL    string
+	Status int
+	Header http.Header
+	Body   []byte
+	// Problem is the error body decoded into the framework's error model, see decodeProblem. It is nil when the
+	// body is not JSON.
+	Problem *erroring.Problem
+
+	kind *erroring.Error
+}
+
+// newStatusError reads the body of the response and closes it.
+func newStatusError(method string, target *url.URL, res *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	_ = res.Body.Close()
+
+	message := fmt.Sprintf("%s %s responded with %s", method, redact(target), res.Status)
+	problem := decodeProblem(res.StatusCode, res.Header.Get("Content-Type"), body)
+	if problem != nil && problem.Detail != "" {
+		message += ": " + problem.Detail
+	}
+
+	return &StatusError{
+		Method:  method,
+		URL:     redact(target),
+		Status:  res.StatusCode,
+		Header:  res.Header,
+		Body:    body,
+		Problem: problem,
+		kind:    erroring.FromStatus(res.StatusCode, message),
+	}
+}
+
+// decodeProblem decodes a JSON error body as a Problem. Bodies that are not problem details, such as the
+// {"error": "..."} bodies sent by older services, have their message used as the detail.
+func decodeProblem(status int, contentType string, body []byte) *erroring.Problem {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != erroring.ProblemContentType && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	var problem erroring.Problem
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return nil
+	}
+	if problem.Detail == "" {
+		if message, ok := problem.Extensions["error"].(string); ok {
+			problem.Detail = message
+		}
+	}
+	if problem.Status == 0 {
+		problem.Status = status
+	}
+	if problem.Type == "" {
+		problem.Type = erroring.BlankType
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(status)
+	}
+	return &problem
+}
+
+// Error describes the failed call.
+func (e *StatusError) Error() string {
+	return e.kind.Error()
+}
+
+// Unwrap returns the erroring.Error matching the status.
+func (e *StatusError) Unwrap() error {
+	return e.kind
+}
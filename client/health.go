@@ -0,0 +1,33 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"github.com/drew-viles/go-web-framework/health"
+)
+
+// HealthCheck returns a check that fails while the circuit to the upstream is open.
+// It is named after the upstream with a _circuit suffix.
+func (c *Client) HealthCheck() health.Check {
+	return health.CheckFunc(c.options.Name+"_circuit", func(context.Context) error {
+		if c.CircuitState() == CircuitOpen {
+			return ErrCircuitOpen
+		}
+		return nil
+	})
+}
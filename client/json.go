@@ -0,0 +1,127 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"net/http"
+)
+
+// Get fetches path and decodes the JSON response into a T.
+func Get[T any](ctx context.Context, c *Client, path string) (T, error) {
+	return DoJSON[T](ctx, c, Request{Method: http.MethodGet, Path: path})
+}
+
+// Post sends body as JSON to path and decodes the JSON response into a Resp.
+func Post[Req, Resp any](ctx context.Context, c *Client, path string, body Req) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPost, path, body)
+}
+
+// Put sends body as JSON to path and decodes the JSON response into a Resp.
+func Put[Req, Resp any](ctx context.Context, c *Client, path string, body Req) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPut, path, body)
+}
+
+// Patch sends body as JSON to path and decodes the JSON response into a Resp.
+func Patch[Req, Resp any](ctx context.Context, c *Client, path string, body Req) (Resp, error) {
+	return sendJSON[Req, Resp](ctx, c, http.MethodPatch, path, body)
+}
+
+// Delete deletes path and decodes the JSON response, if there is one, into a T.
+func Delete[T any](ctx context.Context, c *Client, path string) (T, error) {
+	return DoJSON[T](ctx, c, Request{Method: http.MethodDelete, Path: path})
+}
+
+// DoJSON sends the request and decodes the JSON response into a T. An empty response body leaves T as its zero value.
+// A response outside the 2xx range is returned as a *StatusError whose Problem holds the decoded error body.
+func DoJSON[T any](ctx context.Context, c *Client, request Request) (T, error) {
+	var result T
+	request.Header = withAccept(request.Header)
+
+	res, err := c.Do(ctx, request)
+	if err != nil {
+		return result, err
+	}
+	if len(res.Body) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		return result, erroring.Wrap(erroring.UpstreamError, err, "decoding "+c.Name()+" response")
+	}
+	return result, nil
+}
+
+// Stream sends the request and decodes a JSON array response one element at a time, calling fn with each,
+// so large arrays never have to be held in memory. Returning an error from fn stops the stream and returns it.
+// The timeout covers the whole stream, fn included, so set request.Timeout to how long the stream may take.
+func Stream[T any](ctx context.Context, c *Client, request Request, fn func(T) error) error {
+	request.Header = withAccept(request.Header)
+
+	res, err := c.Open(ctx, request)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	token, err := decoder.Token()
+	if err != nil {
+		return erroring.Wrap(erroring.UpstreamError, err, "decoding "+c.Name()+" response")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return erroring.New(erroring.UpstreamError, fmt.Sprintf("decoding %s response: expected a JSON array, got %v", c.Name(), token))
+	}
+
+	for decoder.More() {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return erroring.Wrap(erroring.UpstreamError, err, "decoding "+c.Name()+" response")
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return erroring.Wrap(erroring.UpstreamError, err, "decoding "+c.Name()+" response")
+	}
+	return nil
+}
+
+// sendJSON encodes body as JSON and sends it with method, decoding the JSON response into a Resp.
+func sendJSON[Req, Resp any](ctx context.Context, c *Client, method, path string, body Req) (Resp, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return DoJSON[Resp](ctx, c, Request{Method: method, Path: path, Body: encoded, ContentType: "application/json"})
+}
+
+// withAccept returns a copy of header asking for JSON, unless it already says what it accepts.
+func withAccept(header http.Header) http.Header {
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Accept") == "" {
+		header.Set("Accept", "application/json, "+erroring.ProblemContentType)
+	}
+	return header
+}
@@ -16,7 +16,14 @@ limitations under the License.
 
 package config
 
+import (
+	"time"
+)
+
 const (
 	HashCost   = 8
 	EmailRegex = `^[\w-\.]+@([\w-]+\.)+[\w-]{2,4}$`
+
+	DefaultTokenExpiry        = time.Hour * 1
+	DefaultRefreshTokenExpiry = time.Hour * 24 * 7
 )
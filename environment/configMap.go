@@ -27,6 +27,16 @@ type web struct {
 	Port        int    `yaml:"port" validate:"omitempty"`
 	DomainShort string `yaml:"domain_short" validate:"omitempty"`
 	SSL         certs
+	CORS        cors `yaml:"cors"`
+}
+
+type cors struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" validate:"omitempty"`
+	AllowedMethods   []string      `yaml:"allowed_methods" validate:"omitempty"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" validate:"omitempty"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" validate:"omitempty"`
+	AllowCredentials bool          `yaml:"allow_credentials" validate:"omitempty"`
+	MaxAge           time.Duration `yaml:"max_age" validate:"omitempty"`
 }
 
 type api struct {
@@ -40,9 +50,10 @@ type security struct {
 }
 
 type certs struct {
-	PrivateKey string `yaml:"private_key" validate:"omitempty"`
-	PublicKey  string `yaml:"public_key" validate:"omitempty"`
-	CAKey      string `yaml:"ca_key" validate:"omitempty"`
+	PrivateKey        string `yaml:"private_key" validate:"omitempty"`
+	PublicKey         string `yaml:"public_key" validate:"omitempty"`
+	CAKey             string `yaml:"ca_key" validate:"omitempty"`
+	RequireClientCert bool   `yaml:"require_client_cert" validate:"omitempty"`
 }
 
 type token struct {
@@ -65,7 +76,13 @@ type configStripe struct {
 	AccountID     string `yaml:"account_id" validate:"omitempty"`
 }
 
+type logConfig struct {
+	Level  string `yaml:"level" validate:"omitempty"`
+	Format string `yaml:"format" validate:"omitempty"`
+}
+
 type ConfigMap struct {
+	Log    logConfig    `yaml:"log"`
 	App    web          `yaml:"web"`
 	Api    api          `yaml:"api"`
 	DB     db           `yaml:"db"`
@@ -18,25 +18,52 @@ limitations under the License.
 package environment
 
 import (
-	"fmt"
+	"github.com/drew-viles/go-web-framework/logging"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
-	"log"
+	"os"
+	"time"
 )
 
-var apiSecret []byte
+var (
+	apiSecret            []byte
+	tokenExpiry          time.Duration
+	refreshTokenInterval time.Duration
+)
 
 // GetAPISecret exists as we don't want others accessing the var for editing purposes.
 func GetAPISecret() []byte {
 	return apiSecret
 }
 
+// GetTokenExpiry returns the configured lifetime of access tokens, zero if none has been configured.
+func GetTokenExpiry() time.Duration {
+	return tokenExpiry
+}
+
+// GetRefreshInterval returns the configured lifetime of refresh tokens, zero if none has been configured.
+func GetRefreshInterval() time.Duration {
+	return refreshTokenInterval
+}
+
+// LoadSecurity sets the API secret and token lifetimes from a ConfigMap.
+// ReadEnvironmentFile calls this for you, it only needs calling when the ConfigMap is built by other means.
+func LoadSecurity(configMap *ConfigMap) {
+	apiSecret = []byte(configMap.Api.ApiSecret)
+	tokenExpiry = configMap.Api.Security.Token.ExpiryDate
+	refreshTokenInterval = configMap.Api.Security.Token.RefreshInterval
+}
+
 // ReadEnvironmentFile reads the content of the web.config yaml file and parses them into a ConfigMap struct.
 func ReadEnvironmentFile() (*ConfigMap, error) {
-	log.Println("reading environment file")
+	logging.Default().Info("reading environment file")
 
 	parseConfig()
 	configMap := ConfigMap{
+		Log: logConfig{
+			Level:  viper.GetString("log.level"),
+			Format: viper.GetString("log.format"),
+		},
 		App: web{
 			FQDN:        viper.GetString("app.fqdn"),
 			Env:         viper.GetString("app.env"),
@@ -44,9 +71,18 @@ func ReadEnvironmentFile() (*ConfigMap, error) {
 			Port:        viper.GetInt("app.port"),
 			DomainShort: viper.GetString("app.domain_short"),
 			SSL: certs{
-				PrivateKey: viper.GetString("app.ssl.private_key"),
-				PublicKey:  viper.GetString("app.ssl.public_key"),
-				CAKey:      viper.GetString("app.ssl.ca_key"),
+				PrivateKey:        viper.GetString("app.ssl.private_key"),
+				PublicKey:         viper.GetString("app.ssl.public_key"),
+				CAKey:             viper.GetString("app.ssl.ca_key"),
+				RequireClientCert: viper.GetBool("app.ssl.require_client_cert"),
+			},
+			CORS: cors{
+				AllowedOrigins:   viper.GetStringSlice("app.cors.allowed_origins"),
+				AllowedMethods:   viper.GetStringSlice("app.cors.allowed_methods"),
+				AllowedHeaders:   viper.GetStringSlice("app.cors.allowed_headers"),
+				ExposedHeaders:   viper.GetStringSlice("app.cors.exposed_headers"),
+				AllowCredentials: viper.GetBool("app.cors.allow_credentials"),
+				MaxAge:           viper.GetDuration("app.cors.max_age"),
 			},
 		},
 		Api: api{
@@ -55,7 +91,7 @@ func ReadEnvironmentFile() (*ConfigMap, error) {
 			Security: security{
 				Token: token{
 					Value:           viper.GetString("api.security.token.value"),
-					ExpiryDate:      viper.GetDuration("api.security.token.expiry_date"),
+					ExpiryDate:      configuredTokenExpiry(),
 					RefreshInterval: viper.GetDuration("api.security.token.refresh_interval"),
 				},
 			},
@@ -73,6 +109,7 @@ func ReadEnvironmentFile() (*ConfigMap, error) {
 			AccountID:     viper.GetString("stripe.account_id"),
 		},
 	}
+	LoadSecurity(&configMap)
 
 	return &configMap, nil
 }
@@ -87,15 +124,25 @@ func parseConfig() {
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Fatalln("the config file was not found in any of the valid locations - /etc/dcp-web/config.yaml, $HOME/dcp-web/config.yaml or ./config.yaml")
+			logging.Default().Error("the config file was not found in any of the valid locations - /etc/dcp-web/config.yaml, $HOME/dcp-web/config.yaml or ./config.yaml")
 		} else {
-			log.Fatalln("something went wrong reading the config file - please ensure it is valid YAML")
+			logging.Default().Error("something went wrong reading the config file - please ensure it is valid YAML", "error", err)
 		}
+		os.Exit(1)
 	}
 
 	viper.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Println("Config file changed:", e.Name)
+		logging.Default().Info("config file changed", "file", e.Name)
 	})
 
 	viper.WatchConfig()
 }
+
+// configuredTokenExpiry reads api.security.token.expiry_time, falling back to the expiry_date key it replaced.
+func configuredTokenExpiry() time.Duration {
+	if viper.IsSet("api.security.token.expiry_time") || !viper.IsSet("api.security.token.expiry_date") {
+		return viper.GetDuration("api.security.token.expiry_time")
+	}
+	logging.Default().Warn("api.security.token.expiry_date is deprecated, use api.security.token.expiry_time")
+	return viper.GetDuration("api.security.token.expiry_date")
+}
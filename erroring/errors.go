@@ -17,9 +17,133 @@ limitations under the License.
 package erroring
 
 import (
+	"context"
 	"errors"
+	"net/http"
 )
 
+// Sentinel errors for each kind of failure, matched with errors.Is. Each carries the HTTP status it is reported as, see StatusOf.
 var (
-	NotFoundError = errors.New("not found")
+	NotFoundError     = errors.New("not found")
+	ConflictError     = errors.New("conflict")
+	UnauthorizedError = errors.New("unauthorized")
+	ForbiddenError    = errors.New("forbidden")
+	ValidationError   = errors.New("validation failed")
+	UpstreamError     = errors.New("upstream failure")
+	TimeoutError      = errors.New("timeout")
+	UnavailableError  = errors.New("service unavailable")
 )
+
+// statuses are the HTTP statuses of the sentinel errors.
+var statuses = []struct {
+	kind   error
+	status int
+}{
+	{NotFoundError, http.StatusNotFound},
+	{ConflictError, http.StatusConflict},
+	{UnauthorizedError, http.StatusUnauthorized},
+	{ForbiddenError, http.StatusForbidden},
+	{ValidationError, http.StatusUnprocessableEntity},
+	{UpstreamError, http.StatusBadGateway},
+	{TimeoutError, http.StatusGatewayTimeout},
+	{UnavailableError, http.StatusServiceUnavailable},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+// Error is an error of a given kind, one of the sentinel errors, with a message, an optional cause and the HTTP status
+// it is reported as. errors.Is matches both its kind and its cause.
+type Error struct {
+	Kind    error
+	Status  int
+	Message string
+	Cause   error
+}
+
+// New creates an Error of the kind, with the kind's status.
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Status: StatusOf(kind), Message: message}
+}
+
+// Wrap creates an Error of the kind caused by cause, with the kind's status.
+func Wrap(kind error, cause error, message string) *Error {
+	return &Error{Kind: kind, Status: StatusOf(kind), Message: message, Cause: cause}
+}
+
+// FromStatus creates an Error of the kind matching an HTTP status, such as one returned by an upstream service.
+// Statuses with no matching kind are reported as UpstreamError.
+func FromStatus(status int, message string) *Error {
+	kind := UpstreamError
+	switch status {
+	case http.StatusNotFound:
+		kind = NotFoundError
+	case http.StatusConflict:
+		kind = ConflictError
+	case http.StatusUnauthorized:
+		kind = UnauthorizedError
+	case http.StatusForbidden:
+		kind = ForbiddenError
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		kind = ValidationError
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		kind = TimeoutError
+	}
+	return New(kind, message)
+}
+
+// Error returns the message followed by the cause, falling back to the kind when there is no message.
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" && e.Kind != nil {
+		message = e.Kind.Error()
+	}
+	if e.Cause != nil {
+		if message == "" {
+			return e.Cause.Error()
+		}
+		return message + ": " + e.Cause.Error()
+	}
+	return message
+}
+
+// Unwrap returns the kind and cause so errors.Is and errors.As can match either.
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	return errs
+}
+
+// StatusOf returns the HTTP status err should be reported with: that of the first Error or Problem in its chain,
+// or of the sentinel it matches, or 500 when it is unknown.
+func StatusOf(err error) int {
+	if status, ok := statusOf(err); ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// statusOf returns the HTTP status of err, reporting whether one is known.
+func statusOf(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	var typed *Error
+	if errors.As(err, &typed) && typed.Status != 0 {
+		return typed.Status, true
+	}
+	var problem *Problem
+	if errors.As(err, &problem) && problem.Status != 0 {
+		return problem.Status, true
+	}
+	for _, s := range statuses {
+		if errors.Is(err, s.kind) {
+			return s.status, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package erroring
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ProblemContentType is the media type Problem responses are sent with.
+const ProblemContentType = "application/problem+json"
+
+// BlankType is the problem type used when nothing more specific than the HTTP status is known.
+const BlankType = "about:blank"
+
+var (
+	mappingsMu sync.RWMutex
+	mappings   []problemMapping
+)
+
+// problemMapping is the Problem an error is reported as, see RegisterProblem.
+type problemMapping struct {
+	target  error
+	problem Problem
+}
+
+// Problem is an API error as described by RFC 9457, Problem Details for HTTP APIs.
+// Extensions hold any further members, such as a list of validation errors, and are rendered alongside the others.
+type Problem struct {
+	Type       string         `json:"type,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	Status     int            `json:"status,omitempty"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Extensions map[string]any `json:"-"`
+}
+
+// NewProblem creates a Problem for the status, titled with its status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   BlankType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error returns the detail of the problem, or its title if there is none, so a Problem can be returned as an error.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.Status)
+}
+
+// With sets an extension member on the problem, returning it so calls can be chained.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// MarshalJSON renders the problem with its extensions as top level members. Extensions never replace the standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	standard, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return standard, err
+	}
+
+	members := map[string]any{}
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(standard, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		members[key] = value
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON reads a problem, keeping any members beyond the standard ones in Extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	type problem Problem
+	var standard problem
+	if err := json.Unmarshal(data, &standard); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, key)
+	}
+	for key, raw := range members {
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if standard.Extensions == nil {
+			standard.Extensions = map[string]any{}
+		}
+		standard.Extensions[key] = value
+	}
+
+	*p = Problem(standard)
+	return nil
+}
+
+// RegisterProblem reports errors matching target, using errors.Is, as a copy of problem with the error as its detail,
+// unless its status is 500 or above, see AsProblem.
+// Type defaults to about:blank and Title to the status text.
+func RegisterProblem(target error, problem Problem) {
+	if problem.Type == "" {
+		problem.Type = BlankType
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	mappingsMu.Lock()
+	defer mappingsMu.Unlock()
+	mappings = append(mappings, problemMapping{target: target, problem: problem})
+}
+
+// AsProblem maps err to a Problem. A Problem in err's chain is returned as it is, otherwise the most recently
+// registered matching problem is used, see RegisterProblem, then one for the status of err, see StatusOf,
+// falling back to a Problem for status.
+// The error's message only becomes the Detail below 500, so internal details of server errors are never sent to the
+// client. Callers wanting a detail for those set it explicitly.
+func AsProblem(err error, status int) *Problem {
+	if err == nil {
+		return NewProblem(status, "")
+	}
+
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	mappingsMu.RLock()
+	defer mappingsMu.RUnlock()
+	for i := len(mappings) - 1; i >= 0; i-- {
+		if errors.Is(err, mappings[i].target) {
+			mapped := mappings[i].problem
+			if mapped.Status != 0 && mapped.Status < http.StatusInternalServerError {
+				mapped.Detail = err.Error()
+			}
+			return &mapped
+		}
+	}
+	if known, ok := statusOf(err); ok {
+		status = known
+	}
+	if status == 0 || status >= http.StatusInternalServerError {
+		return NewProblem(status, "")
+	}
+	return NewProblem(status, err.Error())
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package erroring
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAsProblem(t *testing.T) {
+	errQuota := errors.New("quota exceeded for tenant 42")
+	errStorage := errors.New("disk /var/lib/app is full")
+	RegisterProblem(errQuota, Problem{Type: "https://example.com/quota", Status: http.StatusTooManyRequests})
+	RegisterProblem(errStorage, Problem{Type: "https://example.com/storage", Status: http.StatusInsufficientStorage})
+	explicit := NewProblem(http.StatusServiceUnavailable, "try again shortly")
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		wantStatus int
+		wantType   string
+		wantDetail string
+	}{
+		{name: "client error keeps its message", err: New(NotFoundError, "user not found"), status: http.StatusInternalServerError, wantStatus: http.StatusNotFound, wantType: BlankType, wantDetail: "user not found"},
+		{name: "server error hides its message", err: New(UpstreamError, "GET http://internal/users: 500"), status: http.StatusInternalServerError, wantStatus: http.StatusBadGateway, wantType: BlankType},
+		{name: "unknown error hides its message", err: errors.New("pq: connection refused"), status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantType: BlankType},
+		{name: "unknown error with a client status", err: errors.New("name is taken"), status: http.StatusConflict, wantStatus: http.StatusConflict, wantType: BlankType, wantDetail: "name is taken"},
+		{name: "registered client problem", err: fmt.Errorf("creating user: %w", errQuota), status: http.StatusInternalServerError, wantStatus: http.StatusTooManyRequests, wantType: "https://example.com/quota", wantDetail: "creating user: quota exceeded for tenant 42"},
+		{name: "registered server problem", err: errStorage, status: http.StatusInternalServerError, wantStatus: http.StatusInsufficientStorage, wantType: "https://example.com/storage"},
+		{name: "explicit problem", err: fmt.Errorf("wrapped: %w", explicit), status: http.StatusInternalServerError, wantStatus: http.StatusServiceUnavailable, wantType: BlankType, wantDetail: "try again shortly"},
+		{name: "nil error", status: http.StatusBadRequest, wantStatus: http.StatusBadRequest, wantType: BlankType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := AsProblem(tt.err, tt.status)
+			if problem.Status != tt.wantStatus || problem.Type != tt.wantType || problem.Detail != tt.wantDetail {
+				t.Errorf("AsProblem() = status %d type %q detail %q, want status %d type %q detail %q",
+					problem.Status, problem.Type, problem.Detail, tt.wantStatus, tt.wantType, tt.wantDetail)
+			}
+		})
+	}
+}
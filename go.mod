@@ -1,6 +1,6 @@
 module github.com/drew-viles/go-web-framework
 
-go 1.18
+go 1.21
 
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"github.com/drew-viles/go-web-framework/responses"
+	"net/http"
+)
+
+// LivenessHandler responds 200 while the process is able to serve requests.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	responses.JSON(w, http.StatusOK, c.Liveness())
+}
+
+// ReadinessHandler responds 200 when every critical check passes and 503 otherwise, with the report as the body.
+// The errors of failing checks are only included once SetVerbose has been called.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Readiness(r.Context())
+	if !c.verbose.Load() {
+		for i := range report.Checks {
+			report.Checks[i].Error = ""
+		}
+	}
+	status := http.StatusOK
+	if report.Status == StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	responses.JSON(w, status, report)
+}
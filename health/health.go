@@ -0,0 +1,249 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health runs dependency checks and reports the liveness and readiness of the service.
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/drew-viles/go-web-framework/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is how long a check may run when none is set in its CheckOptions.
+const DefaultTimeout = time.Second * 2
+
+// ErrShuttingDown is reported by readiness once the server has started to shut down.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is a dependency the service needs, such as a database or an upstream API.
+type Check interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function into a Check with the given name.
+func CheckFunc(name string, fn func(ctx context.Context) error) Check {
+	return funcCheck{name: name, fn: fn}
+}
+
+type funcCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c funcCheck) Name() string {
+	return c.name
+}
+
+func (c funcCheck) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// CheckOptions control how a registered check is run.
+type CheckOptions struct {
+	// Timeout bounds a single run of the check, DefaultTimeout is used when it is zero.
+	Timeout time.Duration
+	// CacheFor reuses the last result for this long so frequent probes do not hammer the dependency.
+	CacheFor time.Duration
+	// NonCritical checks are reported as warnings when failing and do not fail readiness.
+	NonCritical bool
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached,omitempty"`
+}
+
+// Report is the outcome of every registered check.
+type Report struct {
+	Status Status   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Checker holds the registered checks. Its zero value is not usable, use NewChecker.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []*registration
+	shuttingDown atomic.Bool
+	verbose      atomic.Bool
+}
+
+// registration is a check along with its options and last result.
+type registration struct {
+	check   Check
+	options CheckOptions
+
+	mu     sync.Mutex
+	last   Result
+	hasRun bool
+}
+
+// NewChecker creates a Checker with no checks.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Register adds a check to those run for readiness, replacing any check already registered with the same name.
+func (c *Checker) Register(check Check, options CheckOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	registered := &registration{check: check, options: options}
+	for i, existing := range c.checks {
+		if existing.check.Name() == check.Name() {
+			c.checks[i] = registered
+			return
+		}
+	}
+	c.checks = append(c.checks, registered)
+}
+
+// SetVerbose sets whether ReadinessHandler includes the error of each failing check in its response.
+// They are left out by default as readiness is usually served unauthenticated, the errors are always logged.
+func (c *Checker) SetVerbose(verbose bool) {
+	c.verbose.Store(verbose)
+}
+
+// SetShuttingDown marks the service as shutting down, failing readiness so no new traffic is routed to it.
+func (c *Checker) SetShuttingDown(shuttingDown bool) {
+	c.shuttingDown.Store(shuttingDown)
+}
+
+// ShuttingDown reports whether SetShuttingDown has been called.
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Liveness reports whether the process is running. It does not run any checks, so a failing dependency
+// does not get the service restarted.
+func (c *Checker) Liveness() Report {
+	return Report{Status: StatusPass}
+}
+
+// Readiness runs every check concurrently and reports whether the service can take traffic.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]*registration{}, c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{
+		Status: StatusPass,
+		Checks: make([]Result, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *registration) {
+			defer wg.Done()
+			report.Checks[i] = check.run(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		report.Status = worst(report.Status, result.Status)
+	}
+	if c.ShuttingDown() {
+		report.Status = StatusFail
+		report.Error = ErrShuttingDown.Error()
+	}
+	return report
+}
+
+// run runs the check, or returns its cached result if that is still fresh.
+func (r *registration) run(ctx context.Context) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hasRun && r.options.CacheFor > 0 && time.Since(r.last.CheckedAt) < r.options.CacheFor {
+		result := r.last
+		result.Cached = true
+		return result
+	}
+
+	timeout := r.options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runCheck(ctx, r.check)
+	result := Result{
+		Name:      r.check.Name(),
+		Status:    StatusPass,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		if r.options.NonCritical {
+			result.Status = StatusWarn
+		}
+		result.Error = err.Error()
+		logging.FromContext(ctx).Warn("health check failed", "check", result.Name, "error", err)
+	}
+
+	r.last = result
+	r.hasRun = true
+	return result
+}
+
+// runCheck runs the check, giving up once ctx is done even if the check ignores it.
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worst returns the more severe of the two statuses.
+func worst(a, b Status) Status {
+	if a == StatusFail || b == StatusFail {
+		return StatusFail
+	}
+	if a == StatusWarn || b == StatusWarn {
+		return StatusWarn
+	}
+	return StatusPass
+}
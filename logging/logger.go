@@ -0,0 +1,121 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging provides the framework's structured, leveled logger built on log/slog.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	redacted = "[REDACTED]"
+)
+
+var (
+	// SensitiveKeys are the attribute keys whose values are always redacted, compared case-insensitively.
+	SensitiveKeys = []string{
+		"authorization", "cookie", "set-cookie", "token", "access_token", "refresh_token", "id_token",
+		"password", "passwd", "secret", "api_secret", "api_key", "claims", "email",
+	}
+
+	defaultLogger atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	defaultLogger.Store(New(Config{}))
+}
+
+// Config configures a logger. Level is one of debug, info, warn or error and Format one of FormatJSON or FormatText.
+// Output defaults to stderr and RedactKeys are redacted in addition to SensitiveKeys.
+type Config struct {
+	Level      string
+	Format     string
+	Output     io.Writer
+	RedactKeys []string
+}
+
+// New creates a logger that redacts sensitive attributes and anything that looks like a JWT.
+func New(cfg Config) *slog.Logger {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	redactKeys := map[string]bool{}
+	for _, key := range append(append([]string{}, SensitiveKeys...), cfg.RedactKeys...) {
+		redactKeys[strings.ToLower(key)] = true
+	}
+
+	options := &slog.HandlerOptions{
+		Level: ParseLevel(cfg.Level),
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			return redact(attr, redactKeys)
+		},
+	}
+
+	if strings.EqualFold(cfg.Format, FormatJSON) {
+		return slog.New(slog.NewJSONHandler(output, options))
+	}
+	return slog.New(slog.NewTextHandler(output, options))
+}
+
+// ParseLevel converts a level name into a slog.Level, defaulting to info when it is not recognised.
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// Default returns the framework logger.
+func Default() *slog.Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the framework logger.
+func SetDefault(logger *slog.Logger) {
+	defaultLogger.Store(logger)
+}
+
+// redact replaces the value of sensitive attributes, and of any string that looks like a JWT.
+func redact(attr slog.Attr, redactKeys map[string]bool) slog.Attr {
+	if redactKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindString && looksLikeJWT(attr.Value.String()) {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// looksLikeJWT reports whether s contains what appears to be a compact serialised JWT.
+func looksLikeJWT(s string) bool {
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "eyJ") && strings.Count(field, ".") == 2 {
+			return true
+		}
+	}
+	return false
+}
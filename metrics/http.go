@@ -0,0 +1,66 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The framework's own metrics, registered with Default.
+var (
+	HTTPRequests = Default.NewCounterVec("http_server_requests_total",
+		"Total HTTP requests handled, by route name, method and status class.", "route", "method", "status_class")
+	HTTPRequestDuration = Default.NewHistogramVec("http_server_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route name, method and status class.", nil, "route", "method", "status_class")
+	HTTPRequestsInFlight = Default.NewGaugeVec("http_server_requests_in_flight",
+		"HTTP requests currently being handled, by route name.", "route")
+
+	ClientRequests = Default.NewCounterVec("http_client_requests_total",
+		"Total outbound HTTP requests, by upstream, method and status class. Transport failures have the status class error.", "upstream", "method", "status_class")
+	ClientRequestDuration = Default.NewHistogramVec("http_client_request_duration_seconds",
+		"Time taken by outbound HTTP requests, by upstream and method.", nil, "upstream", "method")
+	ClientCircuitState = Default.NewGaugeVec("http_client_circuit_state",
+		"State of the circuit breaker for an upstream: 0 closed, 1 half-open, 2 open.", "upstream")
+	ClientRequestsInFlight = Default.NewGaugeVec("http_client_requests_in_flight",
+		"Outbound HTTP calls currently holding a bulkhead slot, by upstream.", "upstream")
+	ClientRejections = Default.NewCounterVec("http_client_rejections_total",
+		"Outbound HTTP calls failed fast without being sent, by upstream and reason.", "upstream", "reason")
+
+	TokenValidationFailures = Default.NewCounterVec("auth_token_validation_failures_total",
+		"Tokens that failed validation, by token type and reason.", "token_type", "reason")
+)
+
+// StatusClass returns the class of a HTTP status code, such as 2xx.
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// Method returns the HTTP method as a label value. Methods outside the standard set are reported as "other", as the
+// method of an incoming request is chosen by the client and must not be able to create new series.
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
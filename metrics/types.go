@@ -0,0 +1,226 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used when none are given, suited to request latencies in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative, to the counter.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+// Value returns the current count.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// CounterVec is a family of counters partitioned by label values.
+type CounterVec struct {
+	*family[Counter]
+}
+
+// NewCounterVec creates and registers a CounterVec.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newFamily(name, help, labels, func() *Counter { return &Counter{} })}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the counter for the label values, given in the order the labels were declared.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values)
+}
+
+// write writes the family in the text exposition format.
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "counter")
+	for _, s := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labelString(v.labels, s.values), formatFloat(s.metric.Value()))
+	}
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Inc adds one to the gauge.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts one from the gauge.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Add adds v to the gauge.
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.value += v
+	g.mu.Unlock()
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// GaugeVec is a family of gauges partitioned by label values.
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+// NewGaugeVec creates and registers a GaugeVec.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newFamily(name, help, labels, func() *Gauge { return &Gauge{} })}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the gauge for the label values, given in the order the labels were declared.
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.with(values)
+}
+
+// write writes the family in the text exposition format.
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "gauge")
+	for _, s := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labelString(v.labels, s.values), formatFloat(s.metric.Value()))
+	}
+}
+
+// Histogram counts observations into cumulative buckets, along with their sum and count.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// snapshot returns a consistent copy of the histogram's state.
+func (h *Histogram) snapshot() (counts []uint64, sum float64, count uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64{}, h.counts...), h.sum, h.count
+}
+
+// HistogramVec is a family of histograms partitioned by label values.
+type HistogramVec struct {
+	*family[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates and registers a HistogramVec. DefaultBuckets are used when buckets is empty.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	v := &HistogramVec{
+		family: newFamily(name, help, labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the histogram for the label values, given in the order the labels were declared.
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values)
+}
+
+// write writes the family in the text exposition format.
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "histogram")
+	for _, s := range v.sorted() {
+		counts, sum, count := s.metric.snapshot()
+		for i, upper := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, labelString(v.labels, s.values, "le", formatFloat(upper)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, labelString(v.labels, s.values, "le", formatFloat(math.Inf(1))), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, labelString(v.labels, s.values), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, labelString(v.labels, s.values), count)
+	}
+}
+
+// newFamily creates an empty family.
+func newFamily[T any](name, help string, labels []string, create func() *T) *family[T] {
+	return &family[T]{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		series:     map[string]*labelled[T]{},
+		create:     create,
+	}
+}
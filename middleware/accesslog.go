@@ -0,0 +1,194 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLogOptions configures the AccessLog middleware.
+// Format is one of AccessLogCommon, AccessLogCombined or AccessLogJSON, defaulting to JSON, and Output defaults to stdout.
+// SampleRate is the fraction of requests logged, anything outside (0, 1) logs them all; server errors are always logged.
+// ExcludePaths are never logged, an entry ending in "*" excluding every path starting with it.
+// TrustProxyHeaders takes the remote IP from X-Forwarded-For or X-Real-IP, only enable it behind a trusted proxy.
+type AccessLogOptions struct {
+	Format            string
+	Output            io.Writer
+	SampleRate        float64
+	ExcludePaths      []string
+	TrustProxyHeaders bool
+}
+
+// accessLogEntry is a single request as written by the AccessLog middleware in JSON.
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Route      string  `json:"route,omitempty"`
+	Protocol   string  `json:"protocol"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	RemoteIP   string  `json:"remote_ip"`
+	RequestID  string  `json:"request_id,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	Referer    string  `json:"referer,omitempty"`
+}
+
+// AccessLog returns middleware writing a line to the access log for every request.
+// The route is the path template of the matched mux route, so it should be added with mux.Router.Use.
+// The query string is left out of the path as it can carry credentials, such as a token.
+func AccessLog(options AccessLogOptions) func(http.Handler) http.Handler {
+	output := options.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excludedPath(r.URL.Path, options.ExcludePaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rec := newStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			if rec.Status() < http.StatusInternalServerError && options.SampleRate > 0 && options.SampleRate < 1 && rand.Float64() >= options.SampleRate {
+				return
+			}
+
+			entry := accessLogEntry{
+				Time:       start.UTC().Format(time.RFC3339Nano),
+				Method:     r.Method,
+				Path:       r.URL.EscapedPath(),
+				Protocol:   r.Proto,
+				Status:     rec.Status(),
+				Bytes:      rec.bytes,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				RemoteIP:   ClientIP(r, options.TrustProxyHeaders),
+				RequestID:  requestIDOf(w, r),
+				UserAgent:  r.UserAgent(),
+				Referer:    r.Referer(),
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				entry.Route, _ = route.GetPathTemplate()
+			}
+
+			line := formatAccessLog(options.Format, start, entry)
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = io.WriteString(output, line)
+		})
+	}
+}
+
+// formatAccessLog renders the entry in the requested format, including the trailing newline.
+func formatAccessLog(format string, start time.Time, entry accessLogEntry) string {
+	switch format {
+	case AccessLogCommon, AccessLogCombined:
+		line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+			entry.RemoteIP, start.Format(clfTimeFormat), entry.Method, entry.Path, entry.Protocol, entry.Status, clfBytes(entry.Bytes))
+		if format == AccessLogCombined {
+			line = fmt.Sprintf(`%s "%s" "%s"`, line, clfString(entry.Referer), clfString(entry.UserAgent))
+		}
+		return line + "\n"
+	default:
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return ""
+		}
+		return string(b) + "\n"
+	}
+}
+
+// clfBytes renders the response size as the Common Log Format expects, "-" for an empty body.
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+// clfString renders a quoted field as the Common Log Format expects, "-" when empty.
+func clfString(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// excludedPath reports whether the path matches any of the exclusions.
+func excludedPath(path string, exclusions []string) bool {
+	for _, exclusion := range exclusions {
+		if prefix, ok := strings.CutSuffix(exclusion, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == exclusion {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIDOf returns the request ID set by the RequestID middleware, falling back to the one echoed in the response.
+func requestIDOf(w http.ResponseWriter, r *http.Request) string {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	return w.Header().Get(RequestIDHeader)
+}
+
+// ClientIP returns the IP address the request came from.
+// When trustProxyHeaders is set the first address in X-Forwarded-For, or X-Real-IP, is preferred over the peer address.
+func ClientIP(r *http.Request, trustProxyHeaders bool) string {
+	if trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -17,33 +17,91 @@ limitations under the License.
 package middleware
 
 import (
+	"context"
 	"errors"
 	"github.com/drew-viles/go-web-framework/auth"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/logging"
 	"github.com/drew-viles/go-web-framework/responses"
-	"log"
 	"net/http"
+	"sync"
 )
 
-// AuthenticationMiddleware allows the request to continue if the provided user access level is greater than the route's access level
-func AuthenticationMiddleware(next http.HandlerFunc, userAccessLevel, routeAccessLevel int) http.HandlerFunc {
+// authResultKey is the context key the outcome of authenticating a request is stored under, see withAuthResult.
+type authResultKey struct{}
+
+// authResult is the outcome of authenticating a request, so its token is only verified once.
+type authResult struct {
+	once      sync.Once
+	principal *auth.Principal
+	err       error
+}
+
+// AuthenticationMiddleware allows the request to continue if the caller's token meets the required access.
+// A missing or invalid token results in a 401, a valid token lacking the access level, roles or permissions in a 403.
+func AuthenticationMiddleware(next http.HandlerFunc, required auth.Requirement) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if userAccessLevel < routeAccessLevel {
-			responses.ERROR(w, http.StatusUnauthorized, errors.New("not authenticated"))
+		principal, err := authenticate(r)
+		if err != nil {
+			logging.FromContext(r.Context()).Info("error validating token", "error", err)
+			responses.ERROR(w, http.StatusUnauthorized, erroring.UnauthorizedError)
+			return
+		}
+
+		err = auth.CheckAccess(r.Context(), principal.Identity(), required)
+		if errors.Is(err, auth.ErrInsufficientAccess) {
+			responses.ERROR(w, http.StatusForbidden, erroring.ForbiddenError)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("error checking access", "error", err)
+			responses.ERROR(w, http.StatusInternalServerError, errors.New("unable to check access"))
 			return
 		}
-		next(w, r)
+		next(w, r.WithContext(withPrincipal(r.Context(), principal)))
 	}
 }
 
 // AuthorisationMiddleware allows the request to continue if a provided jwt is valid.
+// The verified auth.Principal is stored in the request context, see auth.PrincipalFrom.
 func AuthorisationMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := auth.TokenValid(r)
+		principal, err := authenticate(r)
 		if err != nil {
-			log.Printf("error vaildating token: %s\n", err)
-			responses.ERROR(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			logging.FromContext(r.Context()).Info("error validating token", "error", err)
+			responses.ERROR(w, http.StatusUnauthorized, erroring.UnauthorizedError)
 			return
 		}
-		next(w, r)
+		next(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	}
+}
+
+// withPrincipal stores the principal in ctx and adds the user to the request logger, unless already done.
+func withPrincipal(ctx context.Context, principal *auth.Principal) context.Context {
+	if _, ok := auth.PrincipalFrom(ctx); ok {
+		return ctx
+	}
+	ctx = logging.With(ctx, "user_id", principal.UserID)
+	return auth.NewContext(ctx, principal)
+}
+
+// withAuthResult returns a copy of the request in which authenticate remembers its outcome, so middleware running
+// ahead of the authorisation checks, such as RateLimitByUser, can identify the user without verifying the token twice.
+func withAuthResult(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(authResultKey{}).(*authResult); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), authResultKey{}, &authResult{}))
+}
+
+// authenticate is auth.Authenticate, reusing an earlier outcome for requests prepared by withAuthResult.
+func authenticate(r *http.Request) (*auth.Principal, error) {
+	result, ok := r.Context().Value(authResultKey{}).(*authResult)
+	if !ok {
+		return auth.Authenticate(r)
 	}
+	result.once.Do(func() {
+		result.principal, result.err = auth.Authenticate(r)
+	})
+	return result.principal, result.err
 }
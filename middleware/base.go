@@ -20,6 +20,18 @@ import (
 	"net/http"
 )
 
+// Middleware wraps a handler with additional behaviour.
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps next with the middlewares so that the first one listed is the first to run.
+func Chain(next http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next
+}
+
+// JSONContentTypeMiddleware sets the Content-Type of the response to application/json.
 func JSONContentTypeMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -17,9 +17,39 @@ limitations under the License.
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var (
+	ErrCORSCredentialsWithAnyOrigin = errors.New(`a CORS policy cannot allow credentials from any origin ("*"), list the origins instead`)
+
+	defaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"}
+)
+
+// CORSPolicy describes which cross-origin requests are allowed.
+// AllowedOrigins may contain "*" to allow any origin, or a wildcard subdomain such as "https://*.example.com".
+// When AllowedMethods is empty the methods registered for the path are allowed, when AllowedHeaders is empty a
+// sensible default set is allowed, "*" allowing whatever the browser asks for.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// AllowOriginAll is the policy used by routing.Route.EnableCORSOriginAll.
+var AllowOriginAll = &CORSPolicy{
+	AllowedOrigins: []string{"*"},
+}
+
 // CORSAllowOriginAllMiddleware sets the header for Access-Control-Allow-Origin = "*"
 func CORSAllowOriginAllMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,3 +57,146 @@ func CORSAllowOriginAllMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// CORSMiddleware adds the CORS response headers allowed by the policy to cross-origin requests.
+// It panics if the policy is invalid, see CORSPolicy.Validate.
+func CORSMiddleware(next http.HandlerFunc, policy *CORSPolicy) http.HandlerFunc {
+	mustValidate(policy)
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+			if policy.AllowsOrigin(origin) {
+				policy.setOriginHeaders(w, origin)
+				if len(policy.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+				}
+			}
+		}
+		next(w, r)
+	}
+}
+
+// CORSPreflightHandler answers OPTIONS preflight requests for a path, policies holding the CORS policy of each
+// method served on it. Preflights from origins or for methods the policies do not allow are refused with a 403.
+// It panics if any of the policies are invalid, see CORSPolicy.Validate.
+func CORSPreflightHandler(policies map[string]*CORSPolicy) http.HandlerFunc {
+	var methods []string
+	methodsByPolicy := map[*CORSPolicy][]string{}
+	for method, policy := range policies {
+		mustValidate(policy)
+		methods = append(methods, method)
+		methodsByPolicy[policy] = append(methodsByPolicy[policy], method)
+	}
+	sort.Strings(methods)
+	for _, policyMethods := range methodsByPolicy {
+		sort.Strings(policyMethods)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		origin := r.Header.Get("Origin")
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" || requestedMethod == "" {
+			w.Header().Set("Allow", strings.Join(methods, ", ")+", "+http.MethodOptions)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		policy := policies[requestedMethod]
+		if policy == nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		allowedMethods := policy.AllowedMethods
+		if len(allowedMethods) == 0 {
+			allowedMethods = methodsByPolicy[policy]
+		}
+		if !policy.AllowsOrigin(origin) || !containsFold(allowedMethods, requestedMethod) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		policy.setOriginHeaders(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+
+		allowedHeaders := policy.AllowedHeaders
+		if len(allowedHeaders) == 0 {
+			allowedHeaders = defaultCORSHeaders
+		}
+		if containsFold(allowedHeaders, "*") {
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				w.Header().Set("Access-Control-Allow-Headers", requested)
+			}
+		} else {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+		}
+
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AllowsOrigin reports whether requests from the origin are allowed.
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, found := strings.Cut(allowed, "://*.")
+		if !found {
+			continue
+		}
+		prefix := strings.ToLower(scheme + "://")
+		suffix := strings.ToLower("." + host)
+		lowered := strings.ToLower(origin)
+		if strings.HasPrefix(lowered, prefix) && strings.HasSuffix(lowered, suffix) && len(lowered) > len(prefix)+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns ErrCORSCredentialsWithAnyOrigin if the policy allows credentials from any origin,
+// as echoing back every origin along with credentials would let any site make authenticated requests.
+func (p *CORSPolicy) Validate() error {
+	if p.AllowCredentials && containsFold(p.AllowedOrigins, "*") {
+		return ErrCORSCredentialsWithAnyOrigin
+	}
+	return nil
+}
+
+// mustValidate panics if the policy is invalid so a misconfiguration is caught when routes are set up.
+func mustValidate(policy *CORSPolicy) {
+	if err := policy.Validate(); err != nil {
+		panic(fmt.Sprintf("middleware: %v", err))
+	}
+}
+
+// setOriginHeaders sets Access-Control-Allow-Origin and, if enabled, Access-Control-Allow-Credentials.
+// The origin is echoed back rather than using "*" only when the policy lists specific origins, as credentials require.
+func (p *CORSPolicy) setOriginHeaders(w http.ResponseWriter, origin string) {
+	if containsFold(p.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// containsFold reports whether values holds s, ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
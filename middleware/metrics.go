@@ -0,0 +1,60 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"github.com/drew-viles/go-web-framework/metrics"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// Metrics records request counts, latencies and in-flight requests in metrics.Default, labelled by route name.
+// Non-standard methods are labelled "other", see metrics.Method.
+// The route is taken from the matched mux route, so it should be added with mux.Router.Use.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := RouteName(r)
+		inFlight := metrics.HTTPRequestsInFlight.WithLabelValues(route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		method, statusClass := metrics.Method(r.Method), metrics.StatusClass(rec.Status())
+		metrics.HTTPRequests.WithLabelValues(route, method, statusClass).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, method, statusClass).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RouteName returns the name of the mux route matched by the request, falling back to its path template.
+// Requests that matched no route are reported as "unmatched" so they cannot inflate label cardinality.
+func RouteName(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unmatched"
+	}
+	if name := route.GetName(); name != "" {
+		return name
+	}
+	if template, err := route.GetPathTemplate(); err == nil {
+		return template
+	}
+	return "unmatched"
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/auth"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/ratelimit"
+	"github.com/drew-viles/go-web-framework/responses"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitKeyFunc returns the key a request is counted against, such as the client's IP address.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitPolicy limits how often each client may call a route.
+type RateLimitPolicy struct {
+	Limit ratelimit.Limit
+	// Key identifies the client, RateLimitByIP(false) is used when nil.
+	Key RateLimitKeyFunc
+	// Store holds the counts, ratelimit.DefaultStore is used when nil.
+	Store ratelimit.Store
+	// Scope shares one limit between every route using the same scope. Each route is limited separately when empty.
+	Scope string
+}
+
+// RateLimitByIP keys requests on the client's IP address, see ClientIP.
+func RateLimitByIP(trustProxyHeaders bool) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustProxyHeaders)
+	}
+}
+
+// RateLimitByUser keys requests on the authenticated user, see auth.PrincipalFrom.
+// As the rate limit runs before the token is checked by the route, the token is verified here when there is no
+// principal yet; the route's authorisation checks reuse the outcome rather than verifying it again.
+// Requests without a valid token are keyed by fallback, or by IP address if fallback is nil.
+func RateLimitByUser(fallback RateLimitKeyFunc) RateLimitKeyFunc {
+	if fallback == nil {
+		fallback = RateLimitByIP(false)
+	}
+	return func(r *http.Request) string {
+		if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+			return "user:" + principal.UserID.String()
+		}
+		if principal, err := authenticate(r); err == nil {
+			return "user:" + principal.UserID.String()
+		}
+		return fallback(r)
+	}
+}
+
+// RateLimitByAPIKey keys requests on the API key sent in header. The key is hashed so it is never stored.
+// Requests without the header are keyed by fallback, or by IP address if fallback is nil.
+func RateLimitByAPIKey(header string, fallback RateLimitKeyFunc) RateLimitKeyFunc {
+	if fallback == nil {
+		fallback = RateLimitByIP(false)
+	}
+	return func(r *http.Request) string {
+		key := r.Header.Get(header)
+		if key == "" {
+			return fallback(r)
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+}
+
+// RateLimitMiddleware allows the request to continue while the client is within the policy's limit,
+// responding with a 429 otherwise. The RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers are set on every response, Retry-After when the limit has been reached.
+// Requests are allowed if the store cannot be reached, so an outage of the store does not take the service down.
+// It panics if the policy's Limit is invalid so the misconfiguration is caught when routes are set up.
+func RateLimitMiddleware(next http.HandlerFunc, policy RateLimitPolicy) http.HandlerFunc {
+	limiter, err := ratelimit.NewLimiter(policy.Limit, policy.Store)
+	if err != nil {
+		panic(fmt.Sprintf("middleware: invalid rate limit: %v", err))
+	}
+	keyFunc := policy.Key
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP(false)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = withAuthResult(r)
+		scope := policy.Scope
+		if scope == "" {
+			scope = RouteName(r)
+		}
+
+		result, err := limiter.Allow(r.Context(), scope+":"+keyFunc(r))
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("error checking rate limit", "error", err)
+			next(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", seconds(result.Reset))
+		header.Set("RateLimit-Policy", limiter.Limit().Policy())
+
+		if !result.Allowed {
+			header.Set("Retry-After", seconds(result.RetryAfter))
+			responses.ERROR(w, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// seconds formats d as a whole number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"github.com/drew-viles/go-web-framework/metrics"
+	"github.com/drew-viles/go-web-framework/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitByUserVerifiesTokenOnce(t *testing.T) {
+	handler := RateLimitMiddleware(AuthorisationMiddleware(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), RateLimitPolicy{
+		Limit: ratelimit.PerSecond(10),
+		Key:   RateLimitByUser(nil),
+		Store: ratelimit.NewMemoryStore(),
+	})
+	malformed := metrics.TokenValidationFailures.WithLabelValues("access", "malformed")
+	before := malformed.Value()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := malformed.Value() - before; got != 1 {
+		t.Errorf("token verified %v times, want once", got)
+	}
+}
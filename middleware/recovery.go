@@ -0,0 +1,76 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/responses"
+	"github.com/drew-viles/go-web-framework/tracing"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrInternal is the error returned to the client when a handler panics, so nothing about the panic leaks out.
+var ErrInternal = errors.New("internal server error")
+
+// PanicReporter receives every panic recovered by Recovery, for forwarding to an error reporting service.
+// It is called after the panic has been logged and the response written.
+type PanicReporter func(r *http.Request, recovered any, stack []byte)
+
+// Recovery recovers panics from the handlers it wraps, logging the stack along with the request ID and responding
+// with a JSON 500. reporter is optional.
+// http.ErrAbortHandler is re-panicked so the server can abort the response as intended.
+func Recovery(reporter PanicReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newStatusRecorder(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				stack := debug.Stack()
+				logging.FromContext(r.Context()).Error("panic serving request",
+					"route", RouteName(r),
+					"method", r.Method,
+					"panic", fmt.Sprint(recovered),
+					"stack", string(stack),
+				)
+				if span := tracing.SpanFromContext(r.Context()); span != nil {
+					span.RecordError(fmt.Errorf("panic: %v", recovered))
+				}
+
+				// Once the handler has started the response the status can no longer be changed.
+				if !rec.wroteHeader {
+					rec.Header().Set("Content-Type", "application/json")
+					responses.ERROR(rec, http.StatusInternalServerError, ErrInternal)
+				}
+
+				if reporter != nil {
+					reporter(r, recovered, stack)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"github.com/drew-viles/go-web-framework/tracing"
+	"net/http"
+	"strconv"
+)
+
+// Tracing starts a server span for every request, named after the matched route, continuing any trace in the
+// incoming traceparent header. tracing.Default is used when tracer is nil.
+// The route is taken from the matched mux route, so it should be added with mux.Router.Use.
+func Tracing(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := tracer
+			if t == nil {
+				t = tracing.Default()
+			}
+
+			route := RouteName(r)
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := t.Start(ctx, route, tracing.SpanKindServer)
+			defer span.End()
+
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("url.path", r.URL.Path)
+			if requestID := RequestIDFromContext(ctx); requestID != "" {
+				span.SetAttribute("http.request.id", requestID)
+			}
+
+			rec := newStatusRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.Status()
+			span.SetAttribute("http.response.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(tracing.StatusError, strconv.Itoa(status))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenBucket takes a token from the bucket held in state, returning the result and the new state.
+// The state is the number of tokens left and the time, in unix nanoseconds, they were counted.
+func tokenBucket(limit Limit, state []byte, now time.Time) (Result, []byte) {
+	capacity := float64(limit.burst())
+	rate := float64(limit.Requests) / float64(limit.Per)
+
+	tokens := capacity
+	if fields := decodeState(state, 2); fields != nil {
+		last := time.Unix(0, int64(fields[1]))
+		elapsed := now.Sub(last)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, fields[0]+float64(elapsed)*rate)
+	}
+
+	result := Result{Limit: limit.burst()}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = time.Duration(math.Ceil((capacity - tokens) / rate))
+
+	return result, encodeState(tokens, float64(now.UnixNano()))
+}
+
+// slidingWindow counts the request in the window held in state, returning the result and the new state.
+// The state is the start of the current window in unix nanoseconds and the counts of the current and previous windows.
+func slidingWindow(limit Limit, state []byte, now time.Time) (Result, []byte) {
+	window := limit.Per
+	start := now.Truncate(window)
+
+	var current, previous float64
+	if fields := decodeState(state, 3); fields != nil {
+		stateStart := time.Unix(0, int64(fields[0]))
+		switch {
+		case stateStart.Equal(start):
+			current, previous = fields[1], fields[2]
+		case stateStart.Add(window).Equal(start):
+			previous = fields[1]
+		}
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	requests := float64(limit.Requests)
+	estimate := previous*weight + current
+
+	result := Result{Limit: limit.Requests}
+	if estimate+1 <= requests {
+		current++
+		estimate++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = slidingRetryAfter(requests, current, previous, elapsed, window)
+	}
+	result.Remaining = int(math.Max(0, math.Floor(requests-estimate)))
+	// The previous window stops counting once the current one ends, which is when the full limit is available
+	// again unless the current window is already in use, in which case it lasts until the end of the next one.
+	result.Reset = window - elapsed
+	if current > 0 {
+		result.Reset += window
+	}
+
+	return result, encodeState(float64(start.UnixNano()), current, previous)
+}
+
+// slidingRetryAfter returns how long until the weighted count drops far enough for another request.
+func slidingRetryAfter(requests, current, previous float64, elapsed, window time.Duration) time.Duration {
+	if current+1 <= requests && previous > 0 {
+		// Within this window the weight of the previous one has to fall to (requests-1-current)/previous.
+		target := 1 - (requests-1-current)/previous
+		wait := time.Duration(math.Ceil(target*float64(window))) - elapsed
+		if wait > 0 {
+			return wait
+		}
+	}
+	// Otherwise wait for the next window, where this window's count is weighted by how far into it the request is.
+	next := window - elapsed
+	if current > 0 && current+1 > requests {
+		target := 1 - (requests-1)/current
+		next += time.Duration(math.Ceil(target * float64(window)))
+	}
+	return next
+}
+
+// encodeState formats the state as colon separated numbers.
+func encodeState(fields ...float64) []byte {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = strconv.FormatFloat(field, 'f', -1, 64)
+	}
+	return []byte(strings.Join(parts, ":"))
+}
+
+// decodeState parses state written by encodeState, returning nil if it is empty or does not hold n numbers.
+func decodeState(state []byte, n int) []float64 {
+	if len(state) == 0 {
+		return nil
+	}
+	parts := strings.Split(string(state), ":")
+	if len(parts) != n {
+		return nil
+	}
+	fields := make([]float64, n)
+	for i, part := range parts {
+		field, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil
+		}
+		fields[i] = field
+	}
+	return fields
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// step is a request made at an offset from the start of a test, along with the result expected for it.
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// algorithmFunc is tokenBucket or slidingWindow.
+type algorithmFunc func(limit Limit, state []byte, now time.Time) (Result, []byte)
+
+// runSteps makes each request in turn, carrying the state from one to the next.
+func runSteps(t *testing.T, algorithm algorithmFunc, limit Limit, steps []step) {
+	t.Helper()
+	start := time.Unix(1700000000, 0)
+	var state []byte
+	for i, s := range steps {
+		var result Result
+		result, state = algorithm(limit, state, start.Add(s.at))
+		if result.Allowed != s.allowed || result.Remaining != s.remaining || !near(result.Reset, s.reset) || !near(result.RetryAfter, s.retryAfter) {
+			t.Errorf("step %d at %s: got allowed=%v remaining=%d reset=%s retry_after=%s, want allowed=%v remaining=%d reset=%s retry_after=%s",
+				i, s.at, result.Allowed, result.Remaining, result.Reset, result.RetryAfter,
+				s.allowed, s.remaining, s.reset, s.retryAfter)
+		}
+	}
+}
+
+// near reports whether two durations are within a microsecond, as the state holds times as float64 nanoseconds.
+func near(got, want time.Duration) bool {
+	diff := got - want
+	return diff < time.Microsecond && diff > -time.Microsecond
+}
+
+func TestTokenBucket(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "burst then refill",
+			limit: Limit{Requests: 2, Per: time.Second, Burst: 4},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3, reset: 500 * ms},
+				{at: 0, allowed: true, remaining: 2, reset: 1000 * ms},
+				{at: 0, allowed: true, remaining: 1, reset: 1500 * ms},
+				{at: 0, allowed: true, remaining: 0, reset: 2000 * ms},
+				{at: 0, allowed: false, remaining: 0, reset: 2000 * ms, retryAfter: 500 * ms},
+				{at: 250 * ms, allowed: false, remaining: 0, reset: 1750 * ms, retryAfter: 250 * ms},
+				{at: 1000 * ms, allowed: true, remaining: 1, reset: 1500 * ms},
+			},
+		},
+		{
+			name:  "refill is capped at the burst",
+			limit: Limit{Requests: 2, Per: time.Second, Burst: 4},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3, reset: 500 * ms},
+				{at: time.Minute, allowed: true, remaining: 3, reset: 500 * ms},
+			},
+		},
+		{
+			name:  "burst defaults to requests",
+			limit: Limit{Requests: 1, Per: time.Second},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 0, reset: time.Second},
+				{at: 400 * ms, allowed: false, remaining: 0, reset: 600 * ms, retryAfter: 600 * ms},
+				{at: time.Second, allowed: true, remaining: 0, reset: time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, tokenBucket, tt.limit, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "fills the window",
+			limit: Limit{Requests: 2, Per: time.Second, Algorithm: SlidingWindow},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{at: 100 * ms, allowed: true, remaining: 0, reset: 1900 * ms},
+				// The next window weighs this one's 2 requests, so 1 only counts once it is half over.
+				{at: 200 * ms, allowed: false, remaining: 0, reset: 1800 * ms, retryAfter: 1300 * ms},
+			},
+		},
+		{
+			name:  "weights the previous window as it rolls over",
+			limit: Limit{Requests: 4, Per: time.Second, Algorithm: SlidingWindow},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3, reset: 2 * time.Second},
+				{at: 0, allowed: true, remaining: 2, reset: 2 * time.Second},
+				{at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{at: 0, allowed: true, remaining: 0, reset: 2 * time.Second},
+				{at: 0, allowed: false, remaining: 0, reset: 2 * time.Second, retryAfter: 1250 * ms},
+				{at: 1250 * ms, allowed: true, remaining: 0, reset: 1750 * ms},
+				{at: 1250 * ms, allowed: false, remaining: 0, reset: 1750 * ms, retryAfter: 250 * ms},
+				{at: 1500 * ms, allowed: true, remaining: 0, reset: 1500 * ms},
+			},
+		},
+		{
+			name:  "forgets windows more than one period old",
+			limit: Limit{Requests: 1, Per: time.Second, Algorithm: SlidingWindow},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 0, reset: 2 * time.Second},
+				{at: 2500 * ms, allowed: true, remaining: 0, reset: 1500 * ms},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, slidingWindow, tt.limit, tt.steps)
+		})
+	}
+}
+
+func TestDecodeStateRejectsMalformedState(t *testing.T) {
+	for _, state := range []string{"", "1", "1:x", "1:2:3"} {
+		if fields := decodeState([]byte(state), 2); fields != nil {
+			t.Errorf("decodeState(%q) = %v, want nil", state, fields)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ratelimit decides whether a request is within its limit using token bucket or sliding window algorithms,
+// keeping the state of each key in a Store so limits can be shared between instances.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Algorithm is the way requests are counted against a Limit.
+type Algorithm string
+
+const (
+	// TokenBucket refills Burst tokens at a rate of Requests per Per, allowing short bursts above the average rate.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests in any window of length Per, weighting the previous window by how much it overlaps.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+var (
+	ErrInvalidLimit = errors.New("rate limit must allow at least one request per period")
+
+	storeMu      sync.RWMutex
+	defaultStore Store = NewMemoryStore()
+)
+
+// Limit is the number of requests allowed per period.
+type Limit struct {
+	Requests  int
+	Per       time.Duration
+	Algorithm Algorithm
+	// Burst is the capacity of a token bucket, Requests is used when it is zero. It is ignored by SlidingWindow.
+	Burst int
+}
+
+// PerSecond is a token bucket Limit of n requests a second.
+func PerSecond(n int) Limit {
+	return Limit{Requests: n, Per: time.Second, Algorithm: TokenBucket}
+}
+
+// PerMinute is a token bucket Limit of n requests a minute.
+func PerMinute(n int) Limit {
+	return Limit{Requests: n, Per: time.Minute, Algorithm: TokenBucket}
+}
+
+// Policy describes the limit in the form used by the RateLimit-Policy header, e.g. 100;w=60.
+func (l Limit) Policy() string {
+	seconds := int(l.Per.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if l.Algorithm != SlidingWindow && l.burst() != l.Requests {
+		return fmt.Sprintf("%d;w=%d;burst=%d", l.Requests, seconds, l.burst())
+	}
+	return fmt.Sprintf("%d;w=%d", l.Requests, seconds)
+}
+
+// burst returns the capacity of the token bucket.
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// ttl returns how long the state of a key must be kept. A token bucket has to be remembered until it has refilled,
+// otherwise it would start again full, and a sliding window for as long as it is weighted into the next one.
+func (l Limit) ttl() time.Duration {
+	if l.Algorithm == SlidingWindow {
+		return l.Per * 2
+	}
+	refill := time.Duration(float64(l.Per) * float64(l.burst()) / float64(l.Requests))
+	return refill + l.Per
+}
+
+// Result is the outcome of counting a request against a Limit.
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests that can be made in a burst.
+	Limit int
+	// Remaining is the number of requests that can be made immediately.
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request will be allowed, zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// SetStore replaces the store used by limiters created without one. An in-memory store is used by default.
+func SetStore(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+}
+
+// DefaultStore returns the store used by limiters created without one.
+func DefaultStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return defaultStore
+}
+
+// Limiter counts requests against a Limit, keeping the state of each key in a Store.
+type Limiter struct {
+	limit Limit
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a Limiter for the limit. DefaultStore is used when store is nil.
+func NewLimiter(limit Limit, store Store) (*Limiter, error) {
+	if limit.Requests < 1 || limit.Per <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	if limit.Algorithm == "" {
+		limit.Algorithm = TokenBucket
+	}
+	if limit.Algorithm != TokenBucket && limit.Algorithm != SlidingWindow {
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+	return &Limiter{limit: limit, store: store, now: time.Now}, nil
+}
+
+// Limit returns the limit requests are counted against.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow counts a request for key, reporting whether it is within the limit.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	store := l.store
+	if store == nil {
+		store = DefaultStore()
+	}
+
+	var result Result
+	err := store.Update(ctx, key, l.limit.ttl(), func(state []byte) []byte {
+		now := l.now()
+		var next []byte
+		if l.limit.Algorithm == SlidingWindow {
+			result, next = slidingWindow(l.limit, state, now)
+		} else {
+			result, next = tokenBucket(l.limit, state, now)
+		}
+		return next
+	})
+	return result, err
+}
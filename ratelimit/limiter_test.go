@@ -0,0 +1,83 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// expiringStore is a single key Store that discards its state once the ttl has passed on a fake clock.
+type expiringStore struct {
+	now       *time.Time
+	state     []byte
+	expiresAt time.Time
+}
+
+func (s *expiringStore) Update(_ context.Context, _ string, ttl time.Duration, fn func(state []byte) []byte) error {
+	var state []byte
+	if s.now.Before(s.expiresAt) {
+		state = s.state
+	}
+	s.state = fn(state)
+	s.expiresAt = s.now.Add(ttl)
+	return nil
+}
+
+// allowed counts how many requests in a row the limiter allows.
+func allowed(t *testing.T, limiter *Limiter) int {
+	t.Helper()
+	for n := 0; ; n++ {
+		result, err := limiter.Allow(context.Background(), "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			return n
+		}
+	}
+}
+
+func TestLimiterKeepsTokenBucketUntilRefilled(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter, err := NewLimiter(Limit{Requests: 1, Per: time.Second, Burst: 10}, &expiringStore{now: &now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return now }
+
+	if n := allowed(t, limiter); n != 10 {
+		t.Fatalf("allowed %d requests from a full bucket, want 10", n)
+	}
+	now = now.Add(time.Second * 3)
+	if n := allowed(t, limiter); n != 3 {
+		t.Errorf("allowed %d requests after 3s, want the 3 refilled tokens", n)
+	}
+}
+
+func TestNewLimiterRejectsInvalidLimits(t *testing.T) {
+	for _, limit := range []Limit{
+		{Requests: 0, Per: time.Second},
+		{Requests: 1, Per: 0},
+		{Requests: 1, Per: time.Second, Algorithm: "fixed_window"},
+	} {
+		if _, err := NewLimiter(limit, nil); err == nil {
+			t.Errorf("NewLimiter(%+v) error = nil", limit)
+		}
+	}
+}
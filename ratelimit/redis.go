@@ -0,0 +1,292 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultRedisPoolSize is the number of connections a RedisStore opens at most when no PoolSize is set.
+const DefaultRedisPoolSize = 8
+
+// DefaultRedisCommandTimeout is how long a single command may take when no CommandTimeout is set.
+const DefaultRedisCommandTimeout = time.Second
+
+// maxRedisAttempts is how many times an update is retried when another client changes the key at the same time.
+const maxRedisAttempts = 10
+
+var ErrRedisContention = errors.New("rate limit state changed concurrently too many times")
+
+// RedisOptions configure the connection to a Redis compatible server.
+type RedisOptions struct {
+	Address  string
+	Username string
+	Password string
+	DB       int
+	// KeyPrefix is prepended to every key, "ratelimit:" is used when it is empty.
+	KeyPrefix string
+	// PoolSize is the most connections opened at once, further updates wait for one to be free.
+	PoolSize    int
+	DialTimeout time.Duration
+	// CommandTimeout bounds each command sent to the server, unless the context's deadline is sooner.
+	CommandTimeout time.Duration
+}
+
+// RedisStore keeps state in a Redis compatible server, so limits are shared between every instance using it.
+// It only needs GET, SET, WATCH, MULTI and EXEC, so it works with local stand-ins such as Valkey, KeyDB or miniredis.
+type RedisStore struct {
+	options RedisOptions
+	slots   chan struct{}
+	pool    chan *redisConn
+}
+
+// NewRedisStore creates a RedisStore. Connections are opened as they are needed.
+func NewRedisStore(options RedisOptions) *RedisStore {
+	if options.PoolSize <= 0 {
+		options.PoolSize = DefaultRedisPoolSize
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = time.Second * 5
+	}
+	if options.CommandTimeout <= 0 {
+		options.CommandTimeout = DefaultRedisCommandTimeout
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "ratelimit:"
+	}
+	return &RedisStore{
+		options: options,
+		slots:   make(chan struct{}, options.PoolSize),
+		pool:    make(chan *redisConn, options.PoolSize),
+	}
+}
+
+// Update replaces the state of key with the result of fn using an optimistic WATCH/MULTI/EXEC transaction,
+// retrying after a short, random delay if another client changes the key in between.
+func (s *RedisStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	conn, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	key = s.options.KeyPrefix + key
+	for attempt := 0; attempt < maxRedisAttempts; attempt++ {
+		if attempt > 0 {
+			if err = sleep(ctx, time.Duration(rand.Int63n(int64(time.Millisecond)<<attempt))); err != nil {
+				s.put(conn)
+				return err
+			}
+		}
+		committed, err := conn.update(ctx, key, ttl, fn)
+		if err != nil {
+			_ = conn.Close()
+			return err
+		}
+		if committed {
+			s.put(conn)
+			return nil
+		}
+	}
+	s.put(conn)
+	return ErrRedisContention
+}
+
+// Close closes every idle connection.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.pool:
+			_ = conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// get takes an idle connection from the pool, or opens a new one.
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.pool:
+		return conn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: s.options.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.options.Address)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn), timeout: s.options.CommandTimeout}
+	if err := conn.setup(ctx, s.options); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full.
+func (s *RedisStore) put(conn *redisConn) {
+	select {
+	case s.pool <- conn:
+	default:
+		_ = conn.Close()
+	}
+}
+
+// redisConn is a connection speaking RESP, the Redis protocol.
+type redisConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// setup authenticates and selects the database.
+func (c *redisConn) setup(ctx context.Context, options RedisOptions) error {
+	if options.Password != "" {
+		args := []string{"AUTH", options.Password}
+		if options.Username != "" {
+			args = []string{"AUTH", options.Username, options.Password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			return err
+		}
+	}
+	if options.DB != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(options.DB)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update runs one transaction, reporting whether it was committed.
+func (c *redisConn) update(ctx context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) (bool, error) {
+	if _, err := c.do(ctx, "WATCH", key); err != nil {
+		return false, err
+	}
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return false, err
+	}
+	state, _ := reply.([]byte)
+	next := fn(state)
+
+	// Replies to queued commands are read from EXEC.
+	if _, err := c.do(ctx, "MULTI"); err != nil {
+		return false, err
+	}
+	if _, err := c.do(ctx, "SET", key, string(next), "PX", strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil {
+		_, _ = c.do(ctx, "DISCARD")
+		return false, err
+	}
+	reply, err = c.do(ctx, "EXEC")
+	if err != nil {
+		return false, err
+	}
+	// EXEC replies with a null array when a watched key changed.
+	return reply != nil, nil
+}
+
+// do sends a command and reads its reply, giving up after the command timeout or the context's deadline.
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = c.SetDeadline(deadline)
+
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, command); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read reads a single RESP reply. Bulk strings are returned as []byte, nulls as nil.
+func (c *redisConn) read() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	case '*':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		values := make([]any, size)
+		for i := range values {
+			if values[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unknown redis reply type %q", kind)
+	}
+}
+
+// sleep waits for d, returning early with the context's error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
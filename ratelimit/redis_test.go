@@ -0,0 +1,154 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConn is a net.Conn that replies with a script and records what is written to it.
+type fakeConn struct {
+	net.Conn
+	replies  *strings.Reader
+	written  bytes.Buffer
+	deadline time.Time
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)           { return c.replies.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error)          { return c.written.Write(p) }
+func (c *fakeConn) Close() error                         { return nil }
+func (c *fakeConn) SetDeadline(deadline time.Time) error { c.deadline = deadline; return nil }
+
+// newFakeRedis returns a redisConn reading the given replies.
+func newFakeRedis(replies string) (*redisConn, *fakeConn) {
+	fake := &fakeConn{replies: strings.NewReader(replies)}
+	return &redisConn{Conn: fake, reader: bufio.NewReader(fake), timeout: time.Second}, fake
+}
+
+func TestRedisConnEncodesCommands(t *testing.T) {
+	conn, fake := newFakeRedis("+OK\r\n")
+	reply, err := conn.do(context.Background(), "SET", "key", "two words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "OK" {
+		t.Errorf("reply = %#v, want OK", reply)
+	}
+	if want := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$9\r\ntwo words\r\n"; fake.written.String() != want {
+		t.Errorf("wrote %q, want %q", fake.written.String(), want)
+	}
+}
+
+func TestRedisConnReadsReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    any
+		wantErr string
+	}{
+		{name: "simple string", reply: "+OK\r\n", want: "OK"},
+		{name: "error", reply: "-ERR wrong type\r\n", wantErr: "redis: ERR wrong type"},
+		{name: "integer", reply: ":42\r\n", want: int64(42)},
+		{name: "bulk string", reply: "$7\r\nhe\r\nllo\r\n", want: []byte("he\r\nllo")},
+		{name: "empty bulk string", reply: "$0\r\n\r\n", want: []byte{}},
+		{name: "null bulk string", reply: "$-1\r\n", want: nil},
+		{name: "array", reply: "*3\r\n$1\r\na\r\n:1\r\n$-1\r\n", want: []any{[]byte("a"), int64(1), nil}},
+		{name: "null array", reply: "*-1\r\n", want: nil},
+		{name: "missing carriage return", reply: "+OK\n", wantErr: "malformed redis reply"},
+		{name: "unknown type", reply: "?1\r\n", wantErr: "unknown redis reply type"},
+		{name: "truncated bulk string", reply: "$5\r\nhi\r\n", wantErr: "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, _ := newFakeRedis(tt.reply)
+			got, err := conn.read()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisConnUpdate(t *testing.T) {
+	tests := []struct {
+		name          string
+		exec          string
+		wantCommitted bool
+	}{
+		{name: "committed", exec: "*1\r\n+OK\r\n", wantCommitted: true},
+		{name: "watched key changed", exec: "*-1\r\n", wantCommitted: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, fake := newFakeRedis("+OK\r\n$3\r\nold\r\n+OK\r\n+QUEUED\r\n" + tt.exec)
+			var seen []byte
+			committed, err := conn.update(context.Background(), "key", time.Second*2, func(state []byte) []byte {
+				seen = state
+				return []byte("new")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if committed != tt.wantCommitted {
+				t.Errorf("committed = %v, want %v", committed, tt.wantCommitted)
+			}
+			if string(seen) != "old" {
+				t.Errorf("fn was given %q, want the stored state", seen)
+			}
+			if !strings.Contains(fake.written.String(), "$3\r\nSET\r\n$3\r\nkey\r\n$3\r\nnew\r\n$2\r\nPX\r\n$4\r\n2000\r\n") {
+				t.Errorf("wrote %q, want SET with a 2000ms expiry", fake.written.String())
+			}
+		})
+	}
+}
+
+func TestRedisConnDeadline(t *testing.T) {
+	conn, fake := newFakeRedis("+OK\r\n+OK\r\n")
+	before := time.Now()
+	if _, err := conn.do(context.Background(), "PING"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.deadline.Before(before.Add(time.Second)) || fake.deadline.After(time.Now().Add(time.Second)) {
+		t.Errorf("deadline = %s, want the command timeout from now", fake.deadline)
+	}
+
+	soon := time.Now().Add(time.Millisecond * 10)
+	ctx, cancel := context.WithDeadline(context.Background(), soon)
+	defer cancel()
+	if _, err := conn.do(ctx, "PING"); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.deadline.Equal(soon) {
+		t.Errorf("deadline = %s, want the context's sooner deadline %s", fake.deadline, soon)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many updates the MemoryStore makes between removing expired keys.
+const sweepEvery = 1024
+
+// Store holds the state of each rate limited key.
+type Store interface {
+	// Update atomically replaces the state of key with the result of fn, which is given the current state or nil.
+	// The new state may be discarded once ttl has passed without another update.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) error
+}
+
+// MemoryStore keeps state in memory, so limits are per process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	updates int
+}
+
+type memoryEntry struct {
+	state     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}}
+}
+
+// Update replaces the state of key with the result of fn.
+func (s *MemoryStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var state []byte
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		state = entry.state
+	}
+	s.entries[key] = memoryEntry{state: fn(state), expiresAt: now.Add(ttl)}
+
+	s.updates++
+	if s.updates%sweepEvery == 0 {
+		s.sweep(now)
+	}
+	return nil
+}
+
+// sweep removes expired keys.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -19,6 +19,8 @@ package responses
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"github.com/drew-viles/go-web-framework/validation"
 	"net/http"
 )
 
@@ -31,15 +33,58 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
-// ERROR generates a response in json from an error
+// ERROR generates a response in json from an error, use PROBLEM for an application/problem+json response instead.
+// A statusCode of 0 uses the status of err, see erroring.StatusOf, and a nil err is reported with the status text.
+// The request ID set in the X-Request-ID response header by the request ID middleware is included when present.
 func ERROR(w http.ResponseWriter, statusCode int, err error) {
+	if statusCode == 0 {
+		statusCode = erroring.StatusOf(err)
+	}
+	message := http.StatusText(statusCode)
 	if err != nil {
-		JSON(w, statusCode, struct {
-			Error string `json:"error"`
-		}{
-			Error: err.Error(),
-		})
-		return
+		message = err.Error()
+	}
+	JSON(w, statusCode, struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
+	}{
+		Error:     message,
+		RequestID: w.Header().Get("X-Request-ID"),
+	})
+}
+
+// PROBLEM generates an application/problem+json response, using the problem's status or a 500 if it has none.
+// The request ID set in the X-Request-ID response header by the request ID middleware is included when present.
+func PROBLEM(w http.ResponseWriter, problem *erroring.Problem) {
+	status := problem.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if requestID := w.Header().Get("X-Request-ID"); requestID != "" {
+		if _, ok := problem.Extensions["request_id"]; !ok {
+			// The problem is copied so one shared between requests is never changed.
+			withID := *problem
+			withID.Extensions = map[string]any{"request_id": requestID}
+			for key, value := range problem.Extensions {
+				withID.Extensions[key] = value
+			}
+			problem = &withID
+		}
+	}
+
+	w.Header().Set("Content-Type", erroring.ProblemContentType)
+	JSON(w, status, problem)
+}
+
+// VALIDATION generates a 422 application/problem+json response listing the fields that failed validation.
+func VALIDATION(w http.ResponseWriter, fieldErrors []validation.FieldError) {
+	PROBLEM(w, ValidationProblem(fieldErrors))
+}
+
+// ValidationProblem returns the 422 Problem sent by VALIDATION, with the field errors in an "errors" member.
+func ValidationProblem(fieldErrors []validation.FieldError) *erroring.Problem {
+	if fieldErrors == nil {
+		fieldErrors = []validation.FieldError{}
 	}
-	JSON(w, http.StatusBadRequest, nil)
+	return erroring.NewProblem(http.StatusUnprocessableEntity, "request failed validation").With("errors", fieldErrors)
 }
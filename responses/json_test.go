@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"errors"
+	"github.com/drew-viles/go-web-framework/erroring"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestERROR(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		requestID  string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "error", statusCode: http.StatusConflict, err: errors.New("already exists"), wantStatus: http.StatusConflict, wantBody: `{"error":"already exists"}`},
+		{name: "request id", statusCode: http.StatusBadRequest, err: errors.New("bad"), requestID: "abc", wantStatus: http.StatusBadRequest, wantBody: `{"error":"bad","request_id":"abc"}`},
+		{name: "status of the error", err: erroring.New(erroring.NotFoundError, "no such user"), wantStatus: http.StatusNotFound, wantBody: `{"error":"no such user"}`},
+		{name: "nil error", statusCode: http.StatusForbidden, wantStatus: http.StatusForbidden, wantBody: `{"error":"Forbidden"}`},
+		{name: "nil error without a status", wantStatus: http.StatusInternalServerError, wantBody: `{"error":"Internal Server Error"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if tt.requestID != "" {
+				rec.Header().Set("X-Request-ID", tt.requestID)
+			}
+			ERROR(rec, tt.statusCode, tt.err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType == erroring.ProblemContentType {
+				t.Errorf("Content-Type = %s, want ERROR to leave problem details to PROBLEM", contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"github.com/drew-viles/go-web-framework/auth"
+	"net/http"
+)
+
+// LoginRoute returns a Route that issues a token pair on POST to path once authenticate has identified the user.
+func LoginRoute(path string, authenticate func(r *http.Request) (auth.Identity, error)) Route {
+	return Route{
+		Name:            "Login",
+		Description:     "Issues an access and refresh token pair",
+		Path:            path,
+		HandlerFunc:     auth.IssueTokenPairHandler(authenticate),
+		RequestMethod:   http.MethodPost,
+		HasJSONResponse: true,
+	}
+}
+
+// RefreshTokenRoute returns a Route that exchanges a refresh token for a new token pair on POST to path.
+// The user is looked up again through the resolver given to auth.SetIdentityResolver, if one has been set.
+func RefreshTokenRoute(path string) Route {
+	return Route{
+		Name:            "RefreshToken",
+		Description:     "Exchanges a refresh token for a new access and refresh token pair",
+		Path:            path,
+		HandlerFunc:     auth.RefreshTokenHandler,
+		RequestMethod:   http.MethodPost,
+		HasJSONResponse: true,
+	}
+}
+
+// LogoutRoute returns a Route that revokes the caller's tokens on POST to path.
+func LogoutRoute(path string) Route {
+	return Route{
+		Name:                  "Logout",
+		Description:           "Revokes the access token and, if provided, the refresh token",
+		Path:                  path,
+		HandlerFunc:           auth.LogoutHandler,
+		RequestMethod:         http.MethodPost,
+		RequiresAuthorisation: true,
+		HasJSONResponse:       true,
+	}
+}
+
+// JWKSRoute returns a Route publishing the signing keys set with auth.SetKeySet on GET /.well-known/jwks.json.
+func JWKSRoute() Route {
+	return Route{
+		Name:            "JWKS",
+		Description:     "Publishes the public keys tokens are signed with",
+		Path:            "/.well-known/jwks.json",
+		HandlerFunc:     auth.JWKSHandler,
+		RequestMethod:   http.MethodGet,
+		HasJSONResponse: true,
+	}
+}
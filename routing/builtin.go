@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"github.com/drew-viles/go-web-framework/health"
+	"github.com/drew-viles/go-web-framework/metrics"
+	"net/http"
+)
+
+// MetricsRoute returns a Route serving metrics.Default in the Prometheus text exposition format on GET /metrics.
+func MetricsRoute() Route {
+	return Route{
+		Name:          "Metrics",
+		Description:   "Exposes metrics in the Prometheus text exposition format",
+		Path:          "/metrics",
+		HandlerFunc:   metrics.Default.ServeHTTP,
+		RequestMethod: http.MethodGet,
+	}
+}
+
+// LivenessRoutes returns the Routes reporting whether the process is alive on GET /livez and GET /healthz.
+func LivenessRoutes(checker *health.Checker) []Route {
+	liveness := Route{
+		Name:            "Liveness",
+		Description:     "Reports whether the process is alive",
+		Path:            "/livez",
+		HandlerFunc:     checker.LivenessHandler,
+		RequestMethod:   http.MethodGet,
+		HasJSONResponse: true,
+	}
+	healthz := liveness
+	healthz.Name = "Health"
+	healthz.Path = "/healthz"
+	return []Route{liveness, healthz}
+}
+
+// ReadinessRoute returns a Route running the checker's checks to report whether the service can take traffic on GET /readyz.
+func ReadinessRoute(checker *health.Checker) Route {
+	return Route{
+		Name:            "Readiness",
+		Description:     "Reports whether the service and its dependencies are ready to take traffic",
+		Path:            "/readyz",
+		HandlerFunc:     checker.ReadinessHandler,
+		RequestMethod:   http.MethodGet,
+		HasJSONResponse: true,
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"github.com/drew-viles/go-web-framework/logging"
+	"github.com/drew-viles/go-web-framework/middleware"
+	"github.com/gorilla/mux"
+)
+
+// Group is a set of routes, and nested groups, sharing a path prefix, default settings and middleware.
+// Routes inherit RequiresAuthorisation and HasJSONResponse when the group sets them, and ContentType, CORS and
+// RateLimit when they do not set their own. Middlewares run after those of any parent group and before the route's own.
+// An inherited RateLimit limits each route separately unless its policy sets a Scope.
+type Group struct {
+	Name                  string
+	Prefix                string
+	RequiresAuthorisation bool
+	HasJSONResponse       bool
+	ContentType           string
+	CORS                  *middleware.CORSPolicy
+	RateLimit             *middleware.RateLimitPolicy
+	Middlewares           []middleware.Middleware
+	Routes                []Route
+	Groups                []Group
+}
+
+// SetupGroups sets up each group's routes on a mux subrouter for its prefix, nesting subrouters for nested groups.
+func SetupGroups(groups []Group, router *mux.Router, options Options) {
+	for _, group := range groups {
+		logging.Default().Info("setting up route group", "name", group.Name, "prefix", options.pathPrefix+group.Prefix)
+
+		groupRouter := router
+		if group.Prefix != "" {
+			groupRouter = router.PathPrefix(group.Prefix).Subrouter()
+		}
+
+		groupOptions := options
+		groupOptions.pathPrefix = options.pathPrefix + group.Prefix
+		groupOptions.Middlewares = append(append([]middleware.Middleware{}, options.Middlewares...), group.Middlewares...)
+		if group.CORS != nil {
+			groupOptions.CORS = group.CORS
+		}
+
+		routes := make([]Route, len(group.Routes))
+		for i, route := range group.Routes {
+			routes[i] = group.applyDefaults(route)
+		}
+		SetupRoutesWithOptions(&routes, groupRouter, groupOptions)
+
+		nested := make([]Group, len(group.Groups))
+		for i, child := range group.Groups {
+			nested[i] = group.applyGroupDefaults(child)
+		}
+		SetupGroups(nested, groupRouter, groupOptions)
+	}
+}
+
+// applyDefaults returns a copy of the route with the group's defaults filled in.
+func (group Group) applyDefaults(route Route) Route {
+	route.RequiresAuthorisation = route.RequiresAuthorisation || group.RequiresAuthorisation
+	route.HasJSONResponse = route.HasJSONResponse || group.HasJSONResponse
+	if route.ContentType == "" {
+		route.ContentType = group.ContentType
+	}
+	if route.RateLimit == nil {
+		route.RateLimit = group.RateLimit
+	}
+	return route
+}
+
+// applyGroupDefaults returns a copy of a nested group inheriting the group's defaults.
+func (group Group) applyGroupDefaults(child Group) Group {
+	child.RequiresAuthorisation = child.RequiresAuthorisation || group.RequiresAuthorisation
+	child.HasJSONResponse = child.HasJSONResponse || group.HasJSONResponse
+	if child.ContentType == "" {
+		child.ContentType = group.ContentType
+	}
+	if child.RateLimit == nil {
+		child.RateLimit = group.RateLimit
+	}
+	return child
+}
@@ -17,11 +17,12 @@ limitations under the License.
 package routing
 
 import (
-	"fmt"
+	"github.com/drew-viles/go-web-framework/auth"
+	"github.com/drew-viles/go-web-framework/logging"
 	"github.com/drew-viles/go-web-framework/middleware"
 	"github.com/gorilla/mux"
-	"log"
 	"net/http"
+	"sort"
 )
 
 // Route is used to store the information f a single route.
@@ -36,14 +37,68 @@ type Route struct {
 	RequiresAuthorisation  bool
 	RequiresAuthentication bool
 	AccessLevel            int
+	RequiredRoles          []string
+	RequiredPermissions    []string
 	HasJSONResponse        bool
 	EnableCORSOriginAll    bool
+	CORS                   *middleware.CORSPolicy
 	QueryParams            []string
+	RateLimit              *middleware.RateLimitPolicy
+	Middlewares            []middleware.Middleware
+}
+
+// Options are the settings applied to every route set up by SetupRoutesWithOptions.
+type Options struct {
+	// CORS is the policy used by routes that neither set their own CORS policy nor EnableCORSOriginAll.
+	CORS *middleware.CORSPolicy
+	// Middlewares run, in order, for every route once its token and access level have been checked.
+	Middlewares []middleware.Middleware
+
+	// pathPrefix is the prefix of the subrouter the routes are being set up on, needed to serve static paths.
+	pathPrefix string
+}
+
+// requirement returns the access the caller must hold to use the route.
+func (route Route) requirement() auth.Requirement {
+	return auth.Requirement{
+		AccessLevel: route.AccessLevel,
+		Roles:       route.RequiredRoles,
+		Permissions: route.RequiredPermissions,
+	}
+}
+
+// corsPolicy returns the CORS policy for the route, nil if cross-origin requests are not handled.
+func (route Route) corsPolicy(options Options) *middleware.CORSPolicy {
+	switch {
+	case route.CORS != nil:
+		return route.CORS
+	case route.EnableCORSOriginAll:
+		return middleware.AllowOriginAll
+	default:
+		return options.CORS
+	}
 }
 
 // SetupRoutes takes an array of Route and creates a set of routes for the mux router. It will add any middleware, static paths and more as required.
 // It supports authenticated and unauthenticated routes.
 func SetupRoutes(routes *[]Route, router *mux.Router) {
+	SetupRoutesWithOptions(routes, router, Options{})
+}
+
+// SetupRoutesWithOptions is SetupRoutes with settings applied across all routes.
+// An OPTIONS preflight handler is registered for every path with a CORS policy, unless a route already handles OPTIONS on it.
+//
+// Each route's handler is wrapped so that, for every request, the following run in order:
+//  1. CORS headers
+//  2. JSON content type, if HasJSONResponse is set
+//  3. the rate limit, if RateLimit is set, so requests with a missing or invalid token are limited too
+//  4. token validation, if RequiresAuthorisation is set
+//  5. the access check, if AccessLevel, RequiredRoles or RequiredPermissions are set
+//  6. Options.Middlewares, in the order given, including those of any enclosing Group
+//  7. Route.Middlewares, in the order given
+//  8. the route's HandlerFunc
+func SetupRoutesWithOptions(routes *[]Route, router *mux.Router, options Options) {
+	preflights := newPreflights()
 	hasStaticPaths := false
 	var staticPaths []Route
 	for _, route := range *routes {
@@ -53,22 +108,30 @@ func SetupRoutes(routes *[]Route, router *mux.Router) {
 			continue
 		} else {
 			var routeHandler http.HandlerFunc
-			logMessage := "Setting up"
+			logAttrs := []any{
+				"name", route.Name,
+				"method", route.RequestMethod,
+				"path", options.pathPrefix + route.Path,
+				"authenticated", route.RequiresAuthorisation,
+			}
+
+			middlewares := append(append([]middleware.Middleware{}, options.Middlewares...), route.Middlewares...)
+			routeHandler = middleware.Chain(route.HandlerFunc, middlewares...)
 
-			routeHandler = route.HandlerFunc
+			// The access check is wrapped so that it runs after the token has been validated.
+			required := route.requirement()
+			if !required.IsZero() {
+				logAttrs = append(logAttrs, "access_level", required.AccessLevel, "roles", required.Roles, "permissions", required.Permissions)
+				routeHandler = middleware.AuthenticationMiddleware(routeHandler, required)
+			}
 
 			if route.RequiresAuthorisation {
-				logMessage = fmt.Sprintf("%s AUTHENTICATED %s Route: %s, on path: %s", logMessage, route.RequestMethod, route.Name, route.Path)
 				routeHandler = middleware.AuthorisationMiddleware(routeHandler)
-			} else {
-				logMessage = fmt.Sprintf("%s UNAUTHENTICATED %s Route: %s, on path: %s", logMessage, route.RequestMethod, route.Name, route.Path)
 			}
 
-			if route.AccessLevel > 0 {
-				logMessage = fmt.Sprintf("%s WITH access level %d", logMessage, route.AccessLevel)
-				routeHandler = middleware.AuthenticationMiddleware(routeHandler, route.AccessLevel, route.AccessLevel)
-			} else {
-				logMessage = fmt.Sprintf("%s WITHOUT an access level", logMessage)
+			if route.RateLimit != nil {
+				logAttrs = append(logAttrs, "rate_limit", route.RateLimit.Limit.Policy())
+				routeHandler = middleware.RateLimitMiddleware(routeHandler, *route.RateLimit)
 			}
 
 			// HasJSONResponse must be the last check
@@ -77,23 +140,27 @@ func SetupRoutes(routes *[]Route, router *mux.Router) {
 			} else {
 				router.Headers("Content-Type", "text/html")
 			}
-			// HasJSONResponse must be the last check
-			if route.EnableCORSOriginAll {
-				routeHandler = middleware.CORSAllowOriginAllMiddleware(routeHandler)
-				logMessage = fmt.Sprintf("%s with Access-Control-Allow-Origin: *", logMessage)
+			// CORS must be the last check so its headers are present on every response, errors included.
+			policy := route.corsPolicy(options)
+			if policy != nil {
+				routeHandler = middleware.CORSMiddleware(routeHandler, policy)
+				logAttrs = append(logAttrs, "cors_origins", policy.AllowedOrigins)
 			}
+			preflights.add(route.Path, route.RequestMethod, policy)
 
 			if len(route.QueryParams) > 0 {
-				logMessage = fmt.Sprintf("%s has query params: %s", logMessage, route.QueryParams)
-				router.Path(route.Path).Queries(route.QueryParams...).HandlerFunc(routeHandler).Methods(route.RequestMethod)
-				log.Println(logMessage)
+				logAttrs = append(logAttrs, "query_params", route.QueryParams)
+				nameRoute(router.Path(route.Path).Queries(route.QueryParams...).HandlerFunc(routeHandler).Methods(route.RequestMethod), route.Name)
+				logging.Default().Info("setting up route", logAttrs...)
 				continue
 			}
-			router.HandleFunc(route.Path, routeHandler).Methods(route.RequestMethod)
-			log.Println(logMessage)
+			nameRoute(router.HandleFunc(route.Path, routeHandler).Methods(route.RequestMethod), route.Name)
+			logging.Default().Info("setting up route", logAttrs...)
 		}
 	}
 
+	preflights.register(router, options.pathPrefix)
+
 	if hasStaticPaths {
 		rootPath := "/public/"
 
@@ -102,7 +169,7 @@ func SetupRoutes(routes *[]Route, router *mux.Router) {
 			pathPrefix := "/" + route.Path + "/"
 			pathValue := rootPath + route.Path
 
-			handler := http.StripPrefix(pathPrefix,
+			handler := http.StripPrefix(options.pathPrefix+pathPrefix,
 				http.FileServer(http.Dir("."+pathValue)))
 			route.HandlerFunc = handler.ServeHTTP
 			contentType := "text/html"
@@ -112,10 +179,77 @@ func SetupRoutes(routes *[]Route, router *mux.Router) {
 			}
 			router.Headers("Content-Type", contentType)
 
-			logMessage := fmt.Sprintf("Setting up STATIC %s Route: %s, on path: %s", route.RequestMethod, route.Name, pathPrefix)
-			log.Println(logMessage)
+			logging.Default().Info("setting up static route", "name", route.Name, "method", route.RequestMethod, "path", options.pathPrefix+pathPrefix)
 
 			router.PathPrefix(pathPrefix).Handler(handler).Methods(route.RequestMethod)
 		}
 	}
 }
+
+// nameRoute names the mux route so middleware can label requests with it, see middleware.RouteName.
+func nameRoute(muxRoute *mux.Route, name string) {
+	if name != "" {
+		muxRoute.Name(name)
+	}
+}
+
+// preflights collects the methods served on each path and the CORS policy of each of them, so OPTIONS preflight
+// requests can be answered.
+type preflights struct {
+	paths    []string
+	methods  map[string][]string
+	policies map[string]map[string]*middleware.CORSPolicy
+}
+
+// newPreflights creates an empty preflights.
+func newPreflights() *preflights {
+	return &preflights{
+		methods:  map[string][]string{},
+		policies: map[string]map[string]*middleware.CORSPolicy{},
+	}
+}
+
+// add records a method served on the path along with its CORS policy, nil if the route does not handle CORS.
+// Only methods with a policy are offered to preflight requests.
+func (p *preflights) add(path, method string, policy *middleware.CORSPolicy) {
+	if _, ok := p.methods[path]; !ok {
+		p.paths = append(p.paths, path)
+	}
+	p.methods[path] = append(p.methods[path], method)
+	if policy == nil {
+		return
+	}
+	if p.policies[path] == nil {
+		p.policies[path] = map[string]*middleware.CORSPolicy{}
+	}
+	if _, ok := p.policies[path][method]; !ok {
+		p.policies[path][method] = policy
+	}
+}
+
+// register adds a preflight handler for each path with a CORS policy that does not already handle OPTIONS itself.
+func (p *preflights) register(router *mux.Router, pathPrefix string) {
+	for _, path := range p.paths {
+		policies := p.policies[path]
+		if len(policies) == 0 || containsMethod(p.methods[path], http.MethodOptions) {
+			continue
+		}
+		methods := make([]string, 0, len(policies))
+		for method := range policies {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		logging.Default().Info("setting up CORS preflight", "path", pathPrefix+path, "methods", methods)
+		router.HandleFunc(path, middleware.CORSPreflightHandler(policies)).Methods(http.MethodOptions)
+	}
+}
+
+// containsMethod reports whether methods holds method.
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"github.com/drew-viles/go-web-framework/middleware"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreflightOnlyOffersMethodsWithAPolicy(t *testing.T) {
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	policy := &middleware.CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}
+	routes := []Route{
+		{Name: "Read", Path: "/items", RequestMethod: http.MethodGet, HandlerFunc: ok, CORS: policy},
+		{Name: "Delete", Path: "/items", RequestMethod: http.MethodDelete, HandlerFunc: ok},
+	}
+	router := mux.NewRouter()
+	SetupRoutes(&routes, router)
+
+	tests := []struct {
+		name       string
+		origin     string
+		method     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: http.MethodGet, wantStatus: http.StatusNoContent, wantOrigin: "https://app.example.com"},
+		{name: "method without policy", origin: "https://app.example.com", method: http.MethodDelete, wantStatus: http.StatusForbidden},
+		{name: "unknown origin", origin: "https://evil.example.org", method: http.MethodGet, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/items", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if tt.wantOrigin == "" {
+				return
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != http.MethodGet {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, http.MethodGet)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+		})
+	}
+}
+
+func TestCORSRejectsCredentialsWithAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("setting up a route allowing credentials from any origin did not panic")
+		}
+	}()
+	routes := []Route{{
+		Name:          "Read",
+		Path:          "/items",
+		RequestMethod: http.MethodGet,
+		HandlerFunc:   func(http.ResponseWriter, *http.Request) {},
+		CORS:          &middleware.CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	}}
+	SetupRoutes(&routes, mux.NewRouter())
+}
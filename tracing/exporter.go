@@ -0,0 +1,86 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Exporter receives spans once they have ended.
+type Exporter interface {
+	Export(span SpanData) error
+}
+
+// InMemoryExporter keeps exported spans in memory, mainly for use in tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// Export stores the span.
+func (e *InMemoryExporter) Export(span SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData{}, e.spans...)
+}
+
+// Reset forgets every span exported so far.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// StdoutExporter writes each span as a line of JSON, to stdout unless Writer is set.
+type StdoutExporter struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// Export writes the span.
+func (e *StdoutExporter) Export(span SpanData) error {
+	b, err := json.Marshal(span)
+	if err != nil {
+		return err
+	}
+
+	writer := e.Writer
+	if writer == nil {
+		writer = os.Stdout
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = writer.Write(append(b, '\n'))
+	return err
+}
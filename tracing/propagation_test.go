@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+		sampled bool
+	}{
+		{name: "sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sampled: true},
+		{name: "not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "future version with extra fields", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", sampled: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "forbidden version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "extra fields in version 00", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "uppercase", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", wantErr: true},
+		{name: "short span id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTraceparent) {
+					t.Errorf("ParseTraceparent() error = %v, want %v", err, ErrInvalidTraceparent)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent() error = %v", err)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter, "service.name", "test")
+
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), incoming)
+
+	ctx, span := tracer.Start(ctx, "GET /items", SpanKindServer)
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	span.End()
+
+	injected, err := ParseTraceparent(outgoing.Get(TraceparentHeader))
+	if err != nil {
+		t.Fatalf("injected traceparent %q is invalid: %v", outgoing.Get(TraceparentHeader), err)
+	}
+	if injected.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("injected trace id = %s, want the incoming trace id", injected.TraceID)
+	}
+	if injected.SpanID != span.SpanContext().SpanID || !injected.Sampled {
+		t.Errorf("injected span context = %+v, want the sampled server span %s", injected, span.SpanContext().SpanID)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	exported := spans[0]
+	if exported.Name != "GET /items" || exported.Kind != SpanKindServer {
+		t.Errorf("exported span = %s %s, want GET /items server", exported.Name, exported.Kind)
+	}
+	if exported.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || exported.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("exported span trace = %s parent = %s, want the incoming trace and span", exported.TraceID, exported.ParentSpanID)
+	}
+	if exported.Resource["service.name"] != "test" {
+		t.Errorf("exported resource = %v, want service.name=test", exported.Resource)
+	}
+
+	span.End()
+	if len(exporter.Spans()) != 1 {
+		t.Error("ending a span twice exported it again")
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if got := header.Get(TraceparentHeader); got != "" {
+		t.Errorf("traceparent = %q, want none without a span", got)
+	}
+}
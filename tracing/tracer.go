@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"github.com/drew-viles/go-web-framework/logging"
+	"sync/atomic"
+	"time"
+)
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(nil))
+}
+
+// spanKey is the context key the current span is stored under.
+type spanKey struct{}
+
+// remoteKey is the context key a span context extracted from an inbound request is stored under.
+type remoteKey struct{}
+
+// Tracer creates spans and hands the finished ones to its Exporter.
+// A Tracer without an Exporter still creates and propagates spans but discards them when they end.
+type Tracer struct {
+	exporter Exporter
+	resource map[string]string
+}
+
+// NewTracer creates a Tracer exporting to exporter, which may be nil.
+// resource attributes, such as service.name, are attached to every exported span.
+func NewTracer(exporter Exporter, resource ...string) *Tracer {
+	t := &Tracer{exporter: exporter}
+	for i := 0; i+1 < len(resource); i += 2 {
+		if t.resource == nil {
+			t.resource = map[string]string{}
+		}
+		t.resource[resource[i]] = resource[i+1]
+	}
+	return t
+}
+
+// Default returns the framework tracer.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// SetDefault replaces the framework tracer.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Start begins a span that is a child of the span in ctx, or of a remote parent extracted into ctx.
+// It returns a copy of ctx holding the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFrom(ctx)
+
+	sc := SpanContext{
+		SpanID:  newSpanID(),
+		Sampled: true,
+	}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+	}
+
+	span := &Span{
+		tracer: t,
+		ctx:    sc,
+		parent: parent,
+		data: SpanData{
+			Name:     name,
+			Kind:     kind,
+			TraceID:  sc.TraceID.String(),
+			SpanID:   sc.SpanID.String(),
+			Start:    time.Now(),
+			Status:   StatusUnset,
+			Resource: t.resource,
+		},
+	}
+	if parent.IsValid() {
+		span.data.ParentSpanID = parent.SpanID.String()
+	}
+
+	ctx = context.WithValue(ctx, spanKey{}, span)
+	ctx = logging.With(ctx, "trace_id", span.data.TraceID, "span_id", span.data.SpanID)
+	return ctx, span
+}
+
+// export hands the span to the exporter, logging rather than failing if it cannot be exported.
+func (t *Tracer) export(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	if err := t.exporter.Export(data); err != nil {
+		logging.Default().Warn("error exporting span", "span", data.Name, "error", err)
+	}
+}
+
+// SpanFromContext returns the current span, nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFrom returns the span context of the current span, or of the remote parent if no span has started yet.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.ctx
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx whose next span is a child of the remote span context.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
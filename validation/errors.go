@@ -0,0 +1,120 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
+
+// FieldError is a single failed validation rule, in a form that can be sent to clients.
+type FieldError struct {
+	// Field is the name the client knows the field by, see fieldName, with nested fields joined by dots.
+	Field string `json:"field"`
+	// Rule is the validation tag that failed, such as required or email.
+	Rule string `json:"rule"`
+	// Param is the rule's parameter, such as 8 for min=8.
+	Param string `json:"param,omitempty"`
+	// Message is the translated description of the failure.
+	Message string `json:"message"`
+}
+
+// FieldErrors converts the validator.ValidationErrors in err, returned from validating target, into FieldErrors
+// with translated messages. It returns nil if err holds no validation errors.
+func (v *Validator) FieldErrors(err error, target any) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	fieldErrors := make([]FieldError, len(validationErrs))
+	for i, fe := range validationErrs {
+		path, name := fieldPath(reflect.TypeOf(target), fe)
+		fieldErrors[i] = FieldError{
+			Field:   path,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: strings.Replace(fe.Translate(v.Translator), fe.Field(), name, 1),
+		}
+	}
+	return fieldErrors
+}
+
+// fieldPath returns the path to the field from the struct being validated, named with fieldName and without
+// the struct's own name or any embedded structs, along with the name of the field itself.
+func fieldPath(t reflect.Type, fe validator.FieldError) (string, string) {
+	segments := strings.Split(fe.StructNamespace(), ".")
+	if len(segments) < 2 {
+		return fe.Field(), fe.Field()
+	}
+
+	path := make([]string, 0, len(segments)-1)
+	for i, segment := range segments[1:] {
+		goName, index, _ := strings.Cut(segment, "[")
+		if index != "" {
+			index = "[" + index
+		}
+
+		t = indirect(t)
+		if t == nil || t.Kind() != reflect.Struct {
+			path = append(path, segment)
+			continue
+		}
+		field, ok := t.FieldByName(goName)
+		if !ok {
+			path = append(path, segment)
+			t = nil
+			continue
+		}
+
+		t = field.Type
+		for n := strings.Count(index, "["); n > 0; n-- {
+			if t = indirect(t); t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+		// JSON treats the fields of embedded structs without a json tag as those of the enclosing struct,
+		// unless the embedded struct is the one that failed.
+		if field.Anonymous && field.Tag.Get("json") == "" && i < len(segments)-2 {
+			continue
+		}
+		path = append(path, fieldName(field)+index)
+	}
+	return strings.Join(path, "."), path[len(path)-1]
+}
+
+// indirect returns the type t points to, through any number of pointers.
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldName names fields after their json tag, or their path, query or header tag for fields bound from those,
+// falling back to the Go field name. Fields excluded from JSON with "-" keep their Go name.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "path", "query", "header"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
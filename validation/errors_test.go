@@ -0,0 +1,122 @@
+/*
+Copyright 2023 Drew Viles.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"testing"
+)
+
+type Paging struct {
+	Page int `query:"page" validate:"min=1"`
+}
+
+type address struct {
+	Postcode string `json:"postcode" validate:"required"`
+}
+
+type tagged struct {
+	Limit int `json:"limit" validate:"max=10"`
+}
+
+type signup struct {
+	Paging
+	Tagged    tagged    `json:"tagged"`
+	Name      string    `json:"name,omitempty" validate:"required"`
+	Email     string    `json:"email" validate:"required,email"`
+	Password  string    `json:"password" validate:"min=8"`
+	ID        string    `path:"id" validate:"uuid"`
+	Tenant    string    `header:"X-Tenant" validate:"required"`
+	Nickname  string    `validate:"required"`
+	Secret    string    `json:"-" validate:"required"`
+	Address   *address  `json:"address" validate:"required"`
+	Addresses []address `json:"addresses" validate:"dive"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	v := New()
+	target := &signup{
+		Paging:    Paging{Page: 0},
+		Tagged:    tagged{Limit: 11},
+		Email:     "not-an-email",
+		Password:  "short",
+		ID:        "not-a-uuid",
+		Address:   &address{},
+		Addresses: []address{{Postcode: "AB1 2CD"}, {}},
+	}
+	err := v.Validate.Struct(target)
+
+	want := []FieldError{
+		{Field: "page", Rule: "min", Param: "1", Message: "page must be 1 or greater"},
+		{Field: "tagged.limit", Rule: "max", Param: "10", Message: "limit must be 10 or less"},
+		{Field: "name", Rule: "required", Message: "name is a required field"},
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "password", Rule: "min", Param: "8", Message: "password must be at least 8 characters in length"},
+		{Field: "id", Rule: "uuid", Message: "id must be a valid uuid"},
+		{Field: "X-Tenant", Rule: "required", Message: "X-Tenant is a required field"},
+		{Field: "Nickname", Rule: "required", Message: "Nickname is a required field"},
+		{Field: "Secret", Rule: "required", Message: "Secret is a required field"},
+		{Field: "address.postcode", Rule: "required", Message: "postcode is a required field"},
+		{Field: "addresses[1].postcode", Rule: "required", Message: "postcode is a required field"},
+	}
+	if got := v.FieldErrors(fmt.Errorf("binding: %w", err), target); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldErrors() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFieldErrorsKeepsValidatorNames(t *testing.T) {
+	v := New()
+	target := &signup{}
+	err := v.Validate.Struct(target)
+	v.FieldErrors(err, target)
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("Struct() = %v, want validation errors", err)
+	}
+	for _, fe := range validationErrs {
+		if fe.Namespace() != fe.StructNamespace() || fe.Field() != fe.StructField() {
+			t.Errorf("Namespace() = %q, Field() = %q, want Go names %q, %q",
+				fe.Namespace(), fe.Field(), fe.StructNamespace(), fe.StructField())
+		}
+	}
+}
+
+func TestFieldErrorsEmbeddedStructFailing(t *testing.T) {
+	type withPaging struct {
+		*Paging `validate:"required"`
+	}
+	v := New()
+	target := &withPaging{}
+
+	want := []FieldError{{Field: "Paging", Rule: "required", Message: "Paging is a required field"}}
+	if got := v.FieldErrors(v.Validate.Struct(target), target); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldErrors() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFieldErrorsWithoutValidationErrors(t *testing.T) {
+	v := New()
+	for _, err := range []error{nil, errors.New("validator: invalid argument")} {
+		if got := v.FieldErrors(err, &signup{}); got != nil {
+			t.Errorf("FieldErrors(%v) = %+v, want nil", err, got)
+		}
+	}
+}
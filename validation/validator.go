@@ -17,12 +17,12 @@ limitations under the License.
 package validation
 
 import (
+	"github.com/drew-viles/go-web-framework/logging"
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en2 "github.com/go-playground/validator/v10/translations/en"
 	"github.com/google/uuid"
-	"log"
 	"unicode"
 )
 
@@ -49,7 +49,7 @@ type Validator struct {
 // New creates and returns a new validator
 // TODO: needs to be more dynamic as the hardcoded validations at the bottom are not ideal.
 func New() *Validator {
-	log.Println("Setting up translation and validation")
+	logging.Default().Info("setting up translation and validation")
 	v := &Validator{}
 
 	enTranslator := en.New()
@@ -60,14 +60,14 @@ func New() *Validator {
 	v.Translator, found = uni.GetTranslator("en")
 
 	if !found {
-		log.Println("translator not found")
+		logging.Default().Error("translator not found")
 		return nil
 	}
 
 	v.Validate = validator.New()
 
 	if err := en2.RegisterDefaultTranslations(v.Validate, v.Translator); err != nil {
-		log.Printf("error registering default translations: %s", err.Error())
+		logging.Default().Error("error registering default translations", "error", err)
 		return nil
 	}
 	for _, val := range validations {
@@ -97,7 +97,7 @@ func (v *Validator) addTranslator(name, message string, translator ut.Translator
 	})
 
 	if err != nil {
-		log.Printf("error registering default translations: %s", err.Error())
+		logging.Default().Error("error registering translation", "name", name, "error", err)
 		return err
 	}
 	return nil